@@ -0,0 +1,204 @@
+package slim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// AssetManifest maps asset names to fingerprinted names derived from their
+// content, such as "app.js" to "app.3f9a1c2b.js", so that the assets can be
+// cached forever: a new version gets a new URL. Example usage:
+//
+//	assets, err := slim.NewAssetManifest(staticFS, "/assets/")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	s.GET("/assets/*", assets.Handler())
+//	s.Renderer = &slim.TemplateRenderer{
+//		Template: template.Must(template.New("").Funcs(assets.FuncMap()).ParseGlob("views/*.html")),
+//	}
+//
+// and in the templates:
+//
+//	<script src="{{asset "app.js"}}"></script>
+type AssetManifest struct {
+	fsys   fs.FS
+	prefix string
+	// names maps an asset name to its fingerprinted name
+	names map[string]string
+	// assets maps a fingerprinted name to its asset name
+	assets map[string]string
+}
+
+// assetImmutableAge is the max-age of fingerprinted assets, one year.
+const assetImmutableAge = 365 * 24 * time.Hour
+
+// NewAssetManifest builds the manifest of all the files of fsys by hashing
+// their content. prefix is the URL path the assets are served under.
+// Precompressed siblings (.br, .zst and .gz) of another file are not
+// fingerprinted, they are served in place of the file they compress.
+func NewAssetManifest(fsys fs.FS, prefix string) (*AssetManifest, error) {
+	m := newAssetManifest(fsys, prefix)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || isPrecompressedSibling(fsys, name) {
+			return err
+		}
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err = io.Copy(h, f); err != nil {
+			return err
+		}
+		m.add(name, fingerprintName(name, hex.EncodeToString(h.Sum(nil)[:4])))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LoadAssetManifest loads a manifest generated at build time, a JSON object
+// mapping asset names to fingerprinted names as written by
+// `AssetManifest.MarshalJSON`, from the file name of fsys.
+func LoadAssetManifest(fsys fs.FS, prefix, name string) (*AssetManifest, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var names map[string]string
+	if err = json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("slim: invalid asset manifest %s: %w", name, err)
+	}
+	m := newAssetManifest(fsys, prefix)
+	for asset, fingerprinted := range names {
+		if !fs.ValidPath(asset) || !fs.ValidPath(fingerprinted) {
+			return nil, fmt.Errorf("slim: invalid asset manifest %s: invalid path %q", name, asset)
+		}
+		m.add(asset, fingerprinted)
+	}
+	return m, nil
+}
+
+func newAssetManifest(fsys fs.FS, prefix string) *AssetManifest {
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+	if prefix == "//" {
+		prefix = "/"
+	}
+	return &AssetManifest{
+		fsys:   fsys,
+		prefix: prefix,
+		names:  make(map[string]string),
+		assets: make(map[string]string),
+	}
+}
+
+func (m *AssetManifest) add(name, fingerprinted string) {
+	m.names[name] = fingerprinted
+	m.assets[fingerprinted] = name
+}
+
+// fingerprintName inserts hash before the extension of name,
+// "css/app.css" becomes "css/app.<hash>.css".
+func fingerprintName(name, hash string) string {
+	ext := path.Ext(name)
+	if ext == path.Base(name) {
+		// dotfiles such as ".htaccess" have no extension
+		ext = ""
+	}
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+func isPrecompressedSibling(fsys fs.FS, name string) bool {
+	for _, p := range precompressedFiles {
+		if original, ok := strings.CutSuffix(name, p.ext); ok {
+			if _, err := fs.Stat(fsys, original); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MarshalJSON encodes the manifest as a JSON object mapping asset names to
+// fingerprinted names, to be loaded with LoadAssetManifest.
+func (m *AssetManifest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.names)
+}
+
+// Path returns the URL path of the fingerprinted asset name, or the URL path
+// of name itself when it is not in the manifest.
+func (m *AssetManifest) Path(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if fingerprinted, ok := m.names[name]; ok {
+		name = fingerprinted
+	}
+	return m.prefix + name
+}
+
+// FuncMap returns the template function "asset", which resolves the URL
+// path of an asset with Path. Add it to the templates before parsing them.
+func (m *AssetManifest) FuncMap() map[string]any {
+	return map[string]any{"asset": m.Path}
+}
+
+// Handler returns a handler serving the assets of the manifest, it must be
+// registered under the prefix of the manifest with the "*" wildcard:
+//
+//	s.GET("/assets/*", assets.Handler())
+//
+// Fingerprinted names are served with `Cache-Control: public,
+// max-age=31536000, immutable`, asset names with `Cache-Control: no-cache`
+// so that clients revalidate them. Files that are not in the manifest are
+// not served.
+func (m *AssetManifest) Handler() HandlerFunc {
+	filesystem := http.FS(m.fsys)
+	return func(c Context) error {
+		name := strings.TrimPrefix(path.Clean("/"+c.PathParam("*")), "/")
+		fingerprinted, immutable := name, true
+		if asset, ok := m.assets[name]; ok {
+			name = asset
+		} else if fingerprinted, ok = m.names[name]; ok {
+			immutable = false
+		} else {
+			return ErrNotFound
+		}
+
+		file, err := filesystem.Open(name)
+		if err != nil {
+			return ErrNotFound
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return ErrNotFound
+		}
+
+		header := c.Response().Header()
+		// the fingerprinted name changes with the content
+		header.Set(HeaderETag, `"`+strings.ReplaceAll(fingerprinted, `"`, "")+`"`)
+		// the manifest alone decides how long its assets are cached
+		header.Del(HeaderCacheControl)
+		cc := NewCacheControl(header)
+		if immutable {
+			cc.Public().MaxAge(assetImmutableAge).Immutable()
+		} else {
+			cc.NoCache()
+		}
+		return serveFile(c, filesystem, name, file, info)
+	}
+}
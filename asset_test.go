@@ -0,0 +1,121 @@
+package slim
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var testAssets = fstest.MapFS{
+	"app.js":       {Data: []byte("console.log(1)")},
+	"app.js.gz":    {Data: []byte("gzip-bytes")},
+	"css/site.css": {Data: []byte("body{}")},
+	"LICENSE":      {Data: []byte("MIT")},
+	"lib.min.js":   {Data: []byte("lib")},
+}
+
+func TestAssetManifest_Names(t *testing.T) {
+	m, err := NewAssetManifest(testAssets, "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.names) != 4 {
+		t.Fatalf("names=%v", m.names)
+	}
+	js := m.Path("app.js")
+	if !strings.HasPrefix(js, "/assets/app.") || !strings.HasSuffix(js, ".js") || len(js) != len("/assets/app.12345678.js") {
+		t.Fatalf("Path(app.js)=%q", js)
+	}
+	for name, pattern := range map[string]string{"css/site.css": "/assets/css/site.*.css", "LICENSE": "/assets/LICENSE.*", "lib.min.js": "/assets/lib.min.*.js"} {
+		got := m.Path(name)
+		prefix, suffix, _ := strings.Cut(pattern, "*")
+		if !strings.HasPrefix(got, prefix) || !strings.HasSuffix(got, suffix) || got == prefix+suffix {
+			t.Errorf("Path(%q)=%q, want %s", name, got, pattern)
+		}
+	}
+	if got := m.Path("/missing.png"); got != "/assets/missing.png" {
+		t.Errorf("Path(missing)=%q", got)
+	}
+
+	// the same content gets the same fingerprint
+	other, _ := NewAssetManifest(fstest.MapFS{"app.js": {Data: []byte("console.log(1)")}}, "/")
+	if other.Path("app.js") != strings.TrimPrefix(js, "/assets") {
+		t.Fatalf("fingerprint differs: %q vs %q", other.Path("app.js"), js)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadAssetManifest(fstest.MapFS{"manifest.json": {Data: data}}, "/static/", "manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Path("app.js") != "/static"+strings.TrimPrefix(js, "/assets") {
+		t.Fatalf("loaded Path(app.js)=%q", loaded.Path("app.js"))
+	}
+	for _, bad := range []string{`{"app.js": "../x.js"}`, `[1]`} {
+		if _, err = LoadAssetManifest(fstest.MapFS{"m.json": {Data: []byte(bad)}}, "/", "m.json"); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestAssetManifest_Handler(t *testing.T) {
+	m, err := NewAssetManifest(testAssets, "/assets/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New()
+	s.GET("/assets/*", m.Handler())
+	js := m.Path("app.js")
+
+	rw := perform(t, s, http.MethodGet, js, nil, nil)
+	h := rw.Header()
+	if rw.Code != http.StatusOK || rw.Body.String() != "console.log(1)" {
+		t.Fatalf("code=%d body=%q", rw.Code, rw.Body.String())
+	}
+	if h.Get(HeaderCacheControl) != "public, max-age=31536000, immutable" || !strings.HasPrefix(h.Get(HeaderContentType), "text/javascript") {
+		t.Fatalf("headers=%v", h)
+	}
+	etag := h.Get(HeaderETag)
+
+	rw = perform(t, s, http.MethodGet, js, nil, map[string]string{HeaderIfNoneMatch: etag})
+	if rw.Code != http.StatusNotModified {
+		t.Fatalf("conditional: code=%d", rw.Code)
+	}
+
+	rw = perform(t, s, http.MethodGet, js, nil, map[string]string{HeaderAcceptEncoding: "gzip"})
+	if rw.Body.String() != "gzip-bytes" || rw.Header().Get(HeaderContentEncoding) != "gzip" || rw.Header().Get(HeaderETag) == etag {
+		t.Fatalf("precompressed: body=%q headers=%v", rw.Body.String(), rw.Header())
+	}
+
+	rw = perform(t, s, http.MethodGet, "/assets/app.js", nil, nil)
+	if rw.Code != http.StatusOK || rw.Header().Get(HeaderCacheControl) != "no-cache" || rw.Header().Get(HeaderETag) != etag {
+		t.Fatalf("plain name: code=%d headers=%v", rw.Code, rw.Header())
+	}
+
+	for _, target := range []string{"/assets/app.js.gz", "/assets/css/", "/assets/app.00000000.js", "/assets/../asset_test.go"} {
+		if rw = perform(t, s, http.MethodGet, target, nil, nil); rw.Code != http.StatusNotFound {
+			t.Errorf("%s: code=%d", target, rw.Code)
+		}
+	}
+}
+
+func TestAssetManifest_TemplateFunc(t *testing.T) {
+	m, err := NewAssetManifest(testAssets, "/assets/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := template.Must(template.New("page").Funcs(m.FuncMap()).Parse(`<link href="{{asset "css/site.css"}}">`))
+	s := New()
+	s.Renderer = &TemplateRenderer{Template: tmpl}
+	s.GET("/", func(c Context) error { return c.Render(http.StatusOK, "page", nil) })
+	rw := perform(t, s, http.MethodGet, "/", nil, nil)
+	if want := `<link href="` + m.Path("css/site.css") + `">`; rw.Body.String() != want {
+		t.Fatalf("body=%q, want %q", rw.Body.String(), want)
+	}
+}
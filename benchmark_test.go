@@ -350,6 +350,45 @@ func BenchmarkBind_JSON_Small(b *testing.B) {
 	}
 }
 
+type benchQuery struct {
+	Page   int      `query:"page"`
+	Size   int      `query:"size"`
+	Sort   string   `query:"sort"`
+	Order  string   `query:"order"`
+	Search string   `query:"q"`
+	Active bool     `query:"active"`
+	Tags   []string `query:"tag"`
+	Min    float64  `query:"min"`
+}
+
+// Binding a typical query struct, including a key that only matches case-insensitively
+func BenchmarkBind_QueryStruct(b *testing.B) {
+	data := map[string][]string{
+		"page": {"2"}, "Size": {"50"}, "sort": {"name"}, "order": {"asc"},
+		"q": {"slim"}, "active": {"true"}, "tag": {"a", "b"}, "min": {"1.5"},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var dst benchQuery
+		if err := bindData(&dst, data, "query"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBind_QueryRequest(b *testing.B) {
+	benchServe(b, func(s *Slim) {
+		s.GET("/search", func(c Context) error {
+			var q benchQuery
+			if err := c.Bind(&q); err != nil {
+				return err
+			}
+			return c.NoContent(http.StatusOK)
+		})
+	}, http.MethodGet, "/search?page=2&size=50&sort=name&order=asc&q=slim&active=true&tag=a&tag=b&min=1.5", http.StatusOK)
+}
+
 func BenchmarkRouter_Simple(b *testing.B) {
 	benchServe(b, func(s *Slim) {
 		s.GET("/hello", func(c Context) error { return c.String(http.StatusOK, "ok") })
@@ -537,3 +576,40 @@ func BenchmarkRouter_Parallel_Param(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkNegotiator_SliceHit(b *testing.B) {
+	n := NewNegotiator(10, nil)
+	header := "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	n.Slice(header)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n.Slice(header)
+	}
+}
+
+func BenchmarkNegotiator_SliceParallel(b *testing.B) {
+	n := NewNegotiator(10, nil)
+	headers := []string{"application/json", "text/html, */*;q=0.1", "application/xml", "text/plain"}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			n.Slice(headers[i%len(headers)])
+			i++
+		}
+	})
+}
+
+func BenchmarkNegotiator_SliceMiss(b *testing.B) {
+	n := NewNegotiator(10, nil)
+	headers := make([]string, 64)
+	for i := range headers {
+		headers[i] = "application/x-" + strconv.Itoa(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n.Slice(headers[i%len(headers)])
+	}
+}
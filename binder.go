@@ -6,10 +6,14 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 // Binder is the interface that wraps the Bind method.
@@ -18,7 +22,60 @@ type Binder interface {
 }
 
 // DefaultBinder is the default implementation of the Binder interface.
-type DefaultBinder struct{}
+//
+// Besides the request body it binds struct fields from tagged sources: the
+// built-in `path`, `query`, `header` and `cookie` sources, and any source
+// added with RegisterSource, e.g. `session:"user_id"`.
+type DefaultBinder struct {
+	// Order lists the sources bound to the targets that do not implement
+	// BindOrderer, the request body being named "body". Headers, cookies and
+	// registered sources are only bound to structs.
+	// Optional. Default binds path params, query params for GET and DELETE
+	// requests only, headers, cookies, registered sources and the body.
+	Order []string
+
+	mu      sync.RWMutex
+	sources map[string]BindingSource
+	names   []string // names of the registered sources, in registration order
+}
+
+// BindingSource returns the values bound to the struct fields tagged with the
+// name of the source.
+type BindingSource func(c Context) (map[string][]string, error)
+
+// BindOrderer is implemented by bind targets that declare which sources they
+// are bound from, and in which order. Each source COULD override values bound
+// by the previous ones, the request body is named "body":
+//
+//	func (*UpdateUser) BindOrder() []string {
+//		return []string{"body", "path", "session"}
+//	}
+type BindOrderer interface {
+	BindOrder() []string
+}
+
+var builtinBindingSources = map[string]BindingSource{
+	"path": func(c Context) (map[string][]string, error) {
+		params := map[string][]string{}
+		for _, param := range c.PathParams() {
+			params[param.Name] = []string{param.Value}
+		}
+		return params, nil
+	},
+	"query": func(c Context) (map[string][]string, error) {
+		return c.QueryParams(), nil
+	},
+	"header": func(c Context) (map[string][]string, error) {
+		return c.Request().Header, nil
+	},
+	"cookie": func(c Context) (map[string][]string, error) {
+		cookies := map[string][]string{}
+		for _, cookie := range c.Request().Cookies() {
+			cookies[cookie.Name] = append(cookies[cookie.Name], cookie.Value)
+		}
+		return cookies, nil
+	},
+}
 
 // BindUnmarshaler is the interface used to wrap the UnmarshalParam method.
 // Types that don't implement this, but do implement encoding.TextUnmarshaler
@@ -28,27 +85,89 @@ type BindUnmarshaler interface {
 	UnmarshalParam(param string) error
 }
 
-// BindPathParams binds path params to a bindable object
-func BindPathParams(c Context, i any) error {
-	params := map[string][]string{}
-	for _, param := range c.PathParams() {
-		params[param.Name] = []string{param.Value}
+// BindFileUnmarshaler is the interface implemented by types that can bind
+// themselves from an uploaded file, such as a wrapper around the opened file.
+type BindFileUnmarshaler interface {
+	// UnmarshalFile decodes and assigns a value from a multipart file.
+	UnmarshalFile(fh *multipart.FileHeader) error
+}
+
+// BindOptions defines how request bodies are bound. The zero value keeps the
+// lenient behavior of the codecs.
+type BindOptions struct {
+	// MaxBodySize is the largest request body accepted, larger bodies are
+	// rejected with 413. Zero means unlimited.
+	MaxBodySize int64
+	// DisallowUnknownFields rejects JSON objects with keys that do not match
+	// any field of the destination.
+	DisallowUnknownFields bool
+	// DisallowTrailingData rejects JSON bodies with data after the first value.
+	DisallowTrailingData bool
+	// UseNumber decodes JSON numbers into `any` as `json.Number` instead of float64.
+	UseNumber bool
+	// MaxBindIndex is the largest slice index accepted in keys like
+	// `items[0].name`. Zero means DefaultMaxBindIndex.
+	MaxBindIndex int
+	// MaxBindDepth is the deepest nesting accepted in keys like `a.b[0].c`.
+	// Zero means DefaultMaxBindDepth.
+	MaxBindDepth int
+}
+
+var bindOptionsKey = NewKey[BindOptions]("bind options")
+
+// WithBindOptions returns a middleware that overrides `Slim.BindOptions` for
+// the requests it handles, e.g. for a single route:
+//
+//	s.POST("/upload", handler).Use(slim.WithBindOptions(slim.BindOptions{MaxBodySize: 100 << 20}))
+func WithBindOptions(options BindOptions) MiddlewareFunc {
+	return func(c Context, next HandlerFunc) error {
+		bindOptionsKey.Set(c, options)
+		return next(c)
 	}
-	if err := bindData(i, params, "path"); err != nil {
-		return NewHTTPErrorWithInternal(http.StatusBadRequest, err, err.Error())
+}
+
+// BindOptionsOf returns the bind options in effect for the request.
+func BindOptionsOf(c Context) BindOptions {
+	if options, ok := bindOptionsKey.Lookup(c); ok {
+		return options
 	}
-	return nil
+	return c.Slim().BindOptions
+}
+
+// BindPathParams binds path params to a bindable object
+func BindPathParams(c Context, i any) error {
+	return bindSource(c, i, "path", builtinBindingSources["path"])
 }
 
 // BindQueryParams binds query params to bindable object
 func BindQueryParams(c Context, i any) error {
-	if err := bindData(i, c.QueryParams(), "query"); err != nil {
+	return bindSource(c, i, "query", builtinBindingSources["query"])
+}
+
+// BindHeaders binds HTTP headers to a bindable object
+func BindHeaders(c Context, i any) error {
+	return bindSource(c, i, "header", builtinBindingSources["header"])
+}
+
+// BindCookies binds request cookies to a bindable object
+func BindCookies(c Context, i any) error {
+	return bindSource(c, i, "cookie", builtinBindingSources["cookie"])
+}
+
+func bindSource(c Context, i any, tag string, source BindingSource) error {
+	data, err := source(c)
+	if err != nil {
+		return err
+	}
+	if err = bindDataDepth(i, data, tag, newBindDepth(BindOptionsOf(c))); err != nil {
 		return NewHTTPErrorWithInternal(http.StatusBadRequest, err, err.Error())
 	}
 	return nil
 }
 
 // BindBody binds request body contents to bindable object
+// Bodies other than forms are decoded with the codec registered in `Slim.Codecs`
+// for their media type, e.g. `application/json` or `application/problem+json`.
 // NB: then binding forms take note that this implementation uses standard library form parsing
 // which parses form data from BOTH URL and BODY if content type is not MIMEMultipartForm
 // See non-MIMEMultipartForm: https://golang.org/pkg/net/http/#Request.ParseForm
@@ -58,120 +177,504 @@ func BindBody(c Context, i any) (err error) {
 	if req.ContentLength == 0 {
 		return
 	}
-	switch c.Is("json", "xml", "form") {
-	case "json":
-		if err = c.Slim().JSONCodec.Decode(c.Request().Body, i); err != nil {
-			if he, ok := err.(*HTTPError); ok {
-				return he
-			} else if ute, ok := err.(*json.UnmarshalTypeError); ok {
-				return NewHTTPErrorWithInternal(http.StatusBadRequest, err, fmt.Sprintf("Unmarshal type error: expected=%v, got=%v, field=%v, offset=%v", ute.Type, ute.Value, ute.Field, ute.Offset))
-			} else if se, ok := err.(*json.SyntaxError); ok {
-				return NewHTTPErrorWithInternal(http.StatusBadRequest, err, fmt.Sprintf("Syntax error: offset=%v, error=%v", se.Offset, se.Error()))
-			} else {
-				return NewHTTPErrorWithInternal(http.StatusBadRequest, err, err.Error())
-			}
-		}
-	case "xml":
-		if err = c.Slim().XMLCodec.Decode(c.Request().Body, i); err != nil {
-			if he, ok := err.(*HTTPError); ok {
-				return he
-			} else if ute, ok := err.(*xml.UnsupportedTypeError); ok {
-				return NewHTTPErrorWithInternal(http.StatusBadRequest, err, fmt.Sprintf("Unsupported type error: type=%v, error=%v", ute.Type, ute.Error()))
-			} else if se, ok := err.(*xml.SyntaxError); ok {
-				return NewHTTPErrorWithInternal(http.StatusBadRequest, err, fmt.Sprintf("Syntax error: line=%v, error=%v", se.Line, se.Error()))
-			} else {
-				return NewHTTPErrorWithInternal(http.StatusBadRequest, err, err.Error())
-			}
+	options := BindOptionsOf(c)
+	if options.MaxBodySize > 0 {
+		if req.ContentLength > options.MaxBodySize {
+			return ErrStatusRequestEntityTooLarge
 		}
-	case "form":
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, options.MaxBodySize)
+	}
+	if c.Is("form") == "form" {
 		params, err := c.FormParams()
 		if err != nil {
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				return ErrStatusRequestEntityTooLarge.WithInternal(err)
+			}
 			return NewHTTPErrorWithInternal(http.StatusBadRequest, err, err.Error())
 		}
-		if err = bindData(i, params, "form"); err != nil {
+		if err = bindDataDepth(i, params, "form", newBindDepth(options)); err != nil {
 			return NewHTTPErrorWithInternal(http.StatusBadRequest, err, err.Error())
 		}
-	default:
+		if form := req.MultipartForm; form != nil {
+			if err = bindFiles(i, form.File, c.Slim().MultipartMemoryLimit); err != nil {
+				if he, ok := err.(*HTTPError); ok {
+					return he
+				}
+				return NewHTTPErrorWithInternal(http.StatusBadRequest, err, err.Error())
+			}
+		}
+		return nil
+	}
+
+	codec, mediaType, ok := c.Slim().Codecs.Lookup(req.Header.Get(HeaderContentType))
+	if !ok {
 		return ErrUnsupportedMediaType
 	}
+	if mediaType == MIMEApplicationJSON {
+		err = decodeJSON(codec, req.Body, i, options)
+	} else {
+		err = codec.Decode(req.Body, i)
+	}
+	if err == nil {
+		return nil
+	}
+	var mbe *http.MaxBytesError
+	if he, ok := err.(*HTTPError); ok {
+		return he
+	} else if errors.As(err, &mbe) {
+		return ErrStatusRequestEntityTooLarge.WithInternal(err)
+	}
+	switch mediaType {
+	case MIMEApplicationJSON:
+		if field, ok := unknownJSONField(err); ok {
+			return NewHTTPErrorWithInternal(http.StatusBadRequest, err, fmt.Sprintf("Unknown field error: field=%v", field))
+		} else if ute, ok := err.(*json.UnmarshalTypeError); ok {
+			return NewHTTPErrorWithInternal(http.StatusBadRequest, err, fmt.Sprintf("Unmarshal type error: expected=%v, got=%v, field=%v, offset=%v", ute.Type, ute.Value, ute.Field, ute.Offset))
+		} else if se, ok := err.(*json.SyntaxError); ok {
+			return NewHTTPErrorWithInternal(http.StatusBadRequest, err, fmt.Sprintf("Syntax error: offset=%v, error=%v", se.Offset, se.Error()))
+		}
+	case MIMEApplicationXML, MIMETextXML:
+		if ute, ok := err.(*xml.UnsupportedTypeError); ok {
+			return NewHTTPErrorWithInternal(http.StatusBadRequest, err, fmt.Sprintf("Unsupported type error: type=%v, error=%v", ute.Type, ute.Error()))
+		} else if se, ok := err.(*xml.SyntaxError); ok {
+			return NewHTTPErrorWithInternal(http.StatusBadRequest, err, fmt.Sprintf("Syntax error: line=%v, error=%v", se.Line, se.Error()))
+		}
+	}
+	return NewHTTPErrorWithInternal(http.StatusBadRequest, err, err.Error())
+}
+
+// errTrailingData is returned when a JSON body has data after its first value.
+var errTrailingData = errors.New("json: unexpected data after top-level value")
+
+// decodeJSON decodes the body with codec. The standard JSONCodec honors the
+// JSON options, other codecs are used as is.
+func decodeJSON(codec Codec, r io.Reader, i any, options BindOptions) error {
+	if sc, ok := codec.(slimCodec); ok {
+		codec = sc()
+	}
+	if _, ok := codec.(JSONCodec); !ok || !(options.DisallowUnknownFields || options.DisallowTrailingData || options.UseNumber) {
+		return codec.Decode(r, i)
+	}
+	dec := json.NewDecoder(r)
+	if options.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if options.UseNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(i); err != nil {
+		return err
+	}
+	if options.DisallowTrailingData {
+		if _, err := dec.Token(); err != io.EOF {
+			if err == nil {
+				err = errTrailingData
+			}
+			return err
+		}
+	}
 	return nil
 }
 
+// unknownJSONField returns the field named by a DisallowUnknownFields error.
+func unknownJSONField(err error) (string, bool) {
+	field, ok := strings.CutPrefix(err.Error(), "json: unknown field ")
+	if !ok {
+		return "", false
+	}
+	if unquoted, uerr := strconv.Unquote(field); uerr == nil {
+		field = unquoted
+	}
+	return field, true
+}
+
 // BindHeaders binds HTTP headers to a bindable object
 func (b *DefaultBinder) BindHeaders(c Context, i any) error {
-	if err := bindData(i, c.Request().Header, "header"); err != nil {
-		return NewHTTPErrorWithInternal(http.StatusBadRequest, err, err.Error())
+	return BindHeaders(c, i)
+}
+
+// RegisterSource registers a binding source for struct fields tagged with
+// name. It replaces a built-in source with the same name, "body" is reserved
+// for the request body.
+func (b *DefaultBinder) RegisterSource(name string, source BindingSource) {
+	if name == "" || name == "body" || source == nil {
+		panic("slim: invalid binding source " + strconv.Quote(name))
 	}
-	return nil
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sources == nil {
+		b.sources = make(map[string]BindingSource)
+	}
+	if _, ok := b.sources[name]; !ok && builtinBindingSources[name] == nil {
+		b.names = append(b.names, name)
+	}
+	b.sources[name] = source
+}
+
+// BindSource binds a single source, such as "query" or "body", to i.
+func (b *DefaultBinder) BindSource(c Context, i any, name string) error {
+	if name == "body" {
+		return BindBody(c, i)
+	}
+	b.mu.RLock()
+	source, ok := b.sources[name]
+	b.mu.RUnlock()
+	if !ok {
+		source, ok = builtinBindingSources[name]
+	}
+	if !ok {
+		return fmt.Errorf("slim: unknown binding source %q", name)
+	}
+	return bindSource(c, i, name, source)
 }
 
 // Bind implements the `Binder#Bind` function.
-// Binding is done in the following order: 1) path params; 2) query params; 3) request body. Each step COULD override previous
-// step bound values. For single source binding use their own methods BindBody, BindQueryParams, BindPathParams.
+// Binding is done in the order declared by i when it implements BindOrderer,
+// then in the order of b.Order, otherwise in the following order: 1) path params;
+// 2) query params; 3) headers; 4) cookies; 5) registered sources; 6) request body.
+// Each step COULD override previous step bound values. Headers, cookies and registered
+// sources are only bound to structs.
+// For single source binding use their own methods BindBody, BindQueryParams, BindPathParams.
 func (b *DefaultBinder) Bind(c Context, i any) (err error) {
-	if err := BindPathParams(c, i); err != nil {
-		return err
+	var order []string
+	if o, ok := i.(BindOrderer); ok {
+		order = o.BindOrder()
+	} else {
+		order = b.defaultOrder(c, i)
+	}
+	for _, name := range order {
+		if err = b.BindSource(c, i, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *DefaultBinder) defaultOrder(c Context, i any) []string {
+	if b.Order != nil {
+		if t := reflect.TypeOf(i); t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+			return b.Order
+		}
+		order := make([]string, 0, len(b.Order))
+		for _, name := range b.Order {
+			if name == "path" || name == "query" || name == "body" {
+				order = append(order, name)
+			}
+		}
+		return order
 	}
+	order := make([]string, 0, 8)
+	order = append(order, "path")
 	// Issue #1670 - Query params are bound only for GET/DELETE and NOT for usual request with body (POST/PUT/PATCH)
 	// Reasoning here is that parameters in query and bind destination struct could have UNEXPECTED matches and results due that.
 	// i.e. is `&id=1&lang=en` from URL same as `{"id":100,"lang":"de"}` request body and which one should have priority when binding.
-	// This HTTP method check restores pre v4.1.11 behavior and avoids different problems when query is mixed with body
+	// Targets that declare their order with BindOrderer, and binders with an Order, may bind
+	// query params for any method; this default is kept for backward compatibility.
 	if c.Request().Method == http.MethodGet || c.Request().Method == http.MethodDelete {
-		if err = BindQueryParams(c, i); err != nil {
-			return err
-		}
+		order = append(order, "query")
+	}
+	if t := reflect.TypeOf(i); t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		order = append(order, "header", "cookie")
+		b.mu.RLock()
+		order = append(order, b.names...)
+		b.mu.RUnlock()
 	}
-	return BindBody(c, i)
+	return append(order, "body")
 }
 
-// bindData will bind data ONLY fields in destination struct that have EXPLICIT tag
+// Default limits of nested binding, they keep crafted keys such as
+// `items[99999999]` from allocating large amounts of memory. Use
+// `BindOptions` to change them.
+const (
+	// DefaultMaxBindIndex is the largest slice index accepted in keys like `items[0].name`.
+	DefaultMaxBindIndex = 1000
+	// DefaultMaxBindDepth is the deepest nesting accepted in keys like `a.b[0].c`.
+	DefaultMaxBindDepth = 10
+)
+
+// bindDepth tracks the nesting of a binding and its limits.
+type bindDepth struct {
+	level    int
+	maxIndex int
+	maxDepth int
+}
+
+func newBindDepth(options BindOptions) bindDepth {
+	d := bindDepth{maxIndex: options.MaxBindIndex, maxDepth: options.MaxBindDepth}
+	if d.maxIndex <= 0 {
+		d.maxIndex = DefaultMaxBindIndex
+	}
+	if d.maxDepth <= 0 {
+		d.maxDepth = DefaultMaxBindDepth
+	}
+	return d
+}
+
+func (d bindDepth) next() bindDepth {
+	d.level++
+	return d
+}
+
+// bindData will bind data ONLY fields in destination struct that have EXPLICIT tag.
+//
+// The tag value may carry options after the name, e.g. `query:"page,required"`
+// reports an error when the parameter is missing or empty. A `default:"20"`
+// tag provides the input used when the parameter is missing and the field
+// still holds its zero value; for slices the default is split on commas.
+//
+// Struct, slice and map fields are also bound from keys in bracket and dot
+// notation, e.g. `items[0].name`, `address.city`, `tags[]` or `labels[env]`.
+// The fields of nested structs need the same tag as the top level ones.
 func bindData(destination any, data map[string][]string, tag string) error {
-	if destination == nil || len(data) == 0 {
+	return bindDataDepth(destination, data, tag, newBindDepth(BindOptions{}))
+}
+
+func bindDataDepth(destination any, data map[string][]string, tag string, depth bindDepth) error {
+	if destination == nil {
 		return nil
 	}
 	typ := reflect.TypeOf(destination).Elem()
 	val := reflect.ValueOf(destination).Elem()
 
+	// Without input only struct fields can pick up defaults or be required
+	if len(data) == 0 && typ.Kind() != reflect.Struct {
+		return nil
+	}
+
 	// Map
 	if typ.Kind() == reflect.Map {
+		if val.IsNil() {
+			val.Set(reflect.MakeMap(typ))
+		}
+		keepAll := typ.Elem() == reflect.TypeOf([]string(nil))
 		for k, v := range data {
-			val.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v[0]))
+			if keepAll {
+				val.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
+			} else {
+				val.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v[0]))
+			}
 		}
 		return nil
 	}
 
 	// !struct
 	if typ.Kind() != reflect.Struct {
-		if tag == "path" || tag == "query" || tag == "header" {
+		if tag == "path" || tag == "query" || tag == "header" || tag == "cookie" {
 			// incompatible type, data is probably to be found in the body
 			return nil
 		}
 		return errors.New("binding element must be a struct")
 	}
 
+	return bindStruct(val, data, tag, depth)
+}
+
+// bindPlan describes how the fields of a struct type are bound for a tag.
+// Plans are built once per type and tag, and cached in bindPlans.
+type bindPlan struct {
+	fields []bindField
+	// lower maps the lowercased input names to the index of their field, it
+	// is used when an input name does not match a field name exactly.
+	lower map[string]int
+}
+
+type bindField struct {
+	index      int
+	name       string
+	required   bool
+	def        string
+	hasDefault bool
+	embedded   bool // anonymous field, dereferenced when it is a pointer
+	tagErr     bool // anonymous struct field with a tag
+	recurse    bool // untagged struct whose fields are bound with the same data
+	file       bool // bound by bindFiles
+	nestable   bool
+	unmarshal  bool // implements BindUnmarshaler or encoding.TextUnmarshaler
+	kind       reflect.Kind
+	slice      bool
+	// set sets the field, or an element of the field when it is a slice
+	set func(val string, field reflect.Value) error
+}
+
+type bindPlanKey struct {
+	typ reflect.Type
+	tag string
+}
+
+var bindPlans sync.Map // map[bindPlanKey]*bindPlan
+
+var (
+	bindUnmarshalerType     = reflect.TypeOf((*BindUnmarshaler)(nil)).Elem()
+	textUnmarshalerType     = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	bindFileUnmarshalerType = reflect.TypeOf((*BindFileUnmarshaler)(nil)).Elem()
+)
+
+func cachedBindPlan(typ reflect.Type, tag string) *bindPlan {
+	key := bindPlanKey{typ, tag}
+	if plan, ok := bindPlans.Load(key); ok {
+		return plan.(*bindPlan)
+	}
+	plan, _ := bindPlans.LoadOrStore(key, newBindPlan(typ, tag))
+	return plan.(*bindPlan)
+}
+
+func newBindPlan(typ reflect.Type, tag string) *bindPlan {
+	plan := &bindPlan{lower: make(map[string]int)}
 	for i := 0; i < typ.NumField(); i++ {
 		typeField := typ.Field(i)
-		structField := val.Field(i)
-		if typeField.Anonymous {
-			if structField.Kind() == reflect.Ptr {
-				structField = structField.Elem()
+		if !typeField.IsExported() && !typeField.Anonymous {
+			continue
+		}
+		t := typeField.Type
+		if typeField.Anonymous && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		name, options, _ := strings.Cut(typeField.Tag.Get(tag), ",")
+		def, hasDefault := typeField.Tag.Lookup("default")
+		f := bindField{
+			index:      i,
+			name:       name,
+			required:   hasTagOption(options, "required"),
+			def:        def,
+			hasDefault: hasDefault,
+			embedded:   typeField.Anonymous,
+			file:       isFileType(t),
+			nestable:   isNestableType(t),
+			unmarshal:  isUnmarshalerType(t),
+			kind:       t.Kind(),
+			slice:      t.Kind() == reflect.Slice,
+		}
+		switch {
+		case f.file:
+		case name == "":
+			// structs that implement BindUnmarshaler are bound only when they have explicit tag
+			f.recurse = t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(bindUnmarshalerType)
+		case typeField.Anonymous && t.Kind() == reflect.Struct:
+			f.tagErr = true
+		case f.slice:
+			f.set = bindSetter(t.Elem())
+		default:
+			f.set = bindSetter(t)
+		}
+		if name != "" {
+			if _, ok := plan.lower[strings.ToLower(name)]; !ok {
+				plan.lower[strings.ToLower(name)] = len(plan.fields)
 			}
 		}
-		if !structField.CanSet() {
+		plan.fields = append(plan.fields, f)
+	}
+	return plan
+}
+
+// lookupFolded returns the index of the field whose name matches key
+// case-insensitively. ASCII keys are lowercased without allocating.
+func (p *bindPlan) lookupFolded(key string) (int, bool) {
+	var buf [64]byte
+	if len(key) > len(buf) {
+		i, ok := p.lower[strings.ToLower(key)]
+		return i, ok
+	}
+	b := buf[:len(key)]
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c >= utf8.RuneSelf {
+			i, ok := p.lower[strings.ToLower(key)]
+			return i, ok
+		}
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		b[i] = c
+	}
+	i, ok := p.lower[string(b)]
+	return i, ok
+}
+
+// bindSetter returns the function that sets values of type t from a string.
+func bindSetter(t reflect.Type) func(string, reflect.Value) error {
+	kind := t.Kind()
+	if isUnmarshalerType(t) {
+		return func(val string, field reflect.Value) error {
+			_, err := unmarshalField(kind, val, field)
+			return err
+		}
+	}
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits := t.Bits()
+		return func(val string, field reflect.Value) error { return setIntField(val, bits, field) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		bits := t.Bits()
+		return func(val string, field reflect.Value) error { return setUintField(val, bits, field) }
+	case reflect.Float32, reflect.Float64:
+		bits := t.Bits()
+		return func(val string, field reflect.Value) error { return setFloatField(val, bits, field) }
+	case reflect.Bool:
+		return setBoolField
+	case reflect.String:
+		return func(val string, field reflect.Value) error {
+			field.SetString(val)
+			return nil
+		}
+	}
+	return func(val string, field reflect.Value) error { return setWithProperType(kind, val, field) }
+}
+
+func isUnmarshalerType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	pt := reflect.PointerTo(t)
+	return pt.Implements(bindUnmarshalerType) || pt.Implements(textUnmarshalerType)
+}
+
+func bindStruct(val reflect.Value, data map[string][]string, tag string, depth bindDepth) error {
+	plan := cachedBindPlan(val.Type(), tag)
+
+	// Go json.Unmarshal supports case-insensitive binding. However, the
+	// url params are bound case-sensitive which is inconsistent. To
+	// fix this, inputs that do not match a field exactly are matched
+	// through the lowercase index of the plan.
+	var buf [16][]string
+	var folded [][]string
+	for i := range plan.fields {
+		if name := plan.fields[i].name; name != "" {
+			if _, ok := data[name]; !ok {
+				if len(plan.fields) <= len(buf) {
+					folded = buf[:len(plan.fields)]
+				} else {
+					folded = make([][]string, len(plan.fields))
+				}
+				for k, v := range data {
+					if j, ok := plan.lookupFolded(k); ok && folded[j] == nil {
+						folded[j] = v
+					}
+				}
+				break
+			}
+		}
+	}
+
+	for i := range plan.fields {
+		f := &plan.fields[i]
+		structField := val.Field(f.index)
+		if f.embedded && structField.Kind() == reflect.Ptr {
+			structField = structField.Elem()
+		}
+		if !structField.CanSet() || f.file {
+			// file fields are bound from the multipart files by bindFiles
 			continue
 		}
-		structFieldKind := structField.Kind()
-		inputFieldName := typeField.Tag.Get(tag)
-		if typeField.Anonymous && structField.Kind() == reflect.Struct && inputFieldName != "" {
+		if f.tagErr {
 			// if anonymous struct with query/path/form tags, report an error
 			return errors.New("query/path/form tags are not allowed with anonymous struct field")
 		}
 
-		if inputFieldName == "" {
+		if f.name == "" {
 			// If tag is nil, we inspect if the field is a not BindUnmarshaler struct and try to bind data into it (might contains fields with tags).
-			// structs that implement BindUnmarshaler are bound only when they have explicit tag
-			if _, ok := structField.Addr().Interface().(BindUnmarshaler); !ok && structFieldKind == reflect.Struct {
-				if err := bindData(structField.Addr().Interface(), data, tag); err != nil {
+			if f.recurse {
+				if err := bindStruct(structField, data, tag, depth); err != nil {
 					return err
 				}
 			}
@@ -179,51 +682,365 @@ func bindData(destination any, data map[string][]string, tag string) error {
 			continue
 		}
 
-		inputValue, exists := data[inputFieldName]
-		if !exists {
-			// Go json.Unmarshal supports case-insensitive binding. However, the
-			// url params are bound case-sensitive which is inconsistent. To
-			// fix this, we must check all the map values in a
-			// case-insensitive search.
-			for k, v := range data {
-				if strings.EqualFold(k, inputFieldName) {
-					inputValue = v
-					exists = true
-					break
+		inputValue, exists := data[f.name]
+		if !exists && folded != nil {
+			inputValue, exists = folded[i], folded[i] != nil
+		}
+
+		if !exists && f.nestable {
+			if nested := nestedData(data, f.name); nested != nil {
+				if err := bindNested(structField, nested, tag, depth.next()); err != nil {
+					return err
 				}
+				continue
 			}
 		}
 
-		if !exists {
-			continue
+		if !exists || isEmptyInput(inputValue) {
+			if f.hasDefault && structField.IsZero() {
+				inputValue = []string{f.def}
+				if f.slice {
+					inputValue = strings.Split(f.def, ",")
+				}
+			} else if f.required {
+				return fmt.Errorf("missing required %s parameter %q", tag, f.name)
+			} else if !exists || len(inputValue) == 0 {
+				continue
+			}
 		}
 
 		// Call this first, in case we're dealing with an alias to an array type
-		if ok, err := unmarshalField(typeField.Type.Kind(), inputValue[0], structField); ok {
-			if err != nil {
+		if f.unmarshal {
+			if _, err := unmarshalField(f.kind, inputValue[0], structField); err != nil {
 				return err
 			}
 			continue
 		}
 
-		numElems := len(inputValue)
-		if structFieldKind == reflect.Slice && numElems > 0 {
-			sliceOf := structField.Type().Elem().Kind()
-			slice := reflect.MakeSlice(structField.Type(), numElems, numElems)
-			for j := 0; j < numElems; j++ {
-				if err := setWithProperType(sliceOf, inputValue[j], slice.Index(j)); err != nil {
+		if f.slice {
+			// grow a fresh slice in place, the previous backing array may be shared
+			structField.Set(reflect.Zero(structField.Type()))
+			structField.Grow(len(inputValue))
+			structField.SetLen(len(inputValue))
+			for j, v := range inputValue {
+				if err := f.set(v, structField.Index(j)); err != nil {
 					return err
 				}
 			}
-			val.Field(i).Set(slice)
-		} else if err := setWithProperType(typeField.Type.Kind(), inputValue[0], structField); err != nil {
+		} else if err := f.set(inputValue[0], structField); err != nil {
 			return err
+		}
+	}
+	return nil
+}
+
+var (
+	fileHeaderType    = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType   = reflect.TypeOf([]*multipart.FileHeader(nil))
+	readCloserType    = reflect.TypeOf((*io.ReadCloser)(nil)).Elem()
+	multipartFileType = reflect.TypeOf((*multipart.File)(nil)).Elem()
+)
+
+// isFileType reports whether fields of type t are bound from multipart files.
+func isFileType(t reflect.Type) bool {
+	switch t {
+	case fileHeaderType, fileHeadersType, readCloserType, multipartFileType:
+		return true
+	}
+	return reflect.PointerTo(t).Implements(bindFileUnmarshalerType)
+}
 
+// bindFiles binds multipart files to the fields of destination with a `form`
+// tag. Fields may be `*multipart.FileHeader`, `[]*multipart.FileHeader`,
+// `io.ReadCloser`, `multipart.File` or implement BindFileUnmarshaler.
+// Files opened for `io.ReadCloser` and `multipart.File` fields must be closed
+// by the handler, unless binding fails: they are closed before returning.
+// Files larger than limit are rejected with 413.
+func bindFiles(destination any, files map[string][]*multipart.FileHeader, limit int64) error {
+	var opened []reflect.Value
+	err := bindFileFields(destination, files, limit, &opened)
+	if err != nil {
+		// the handler does not close the files when binding fails
+		for _, field := range opened {
+			field.Interface().(io.Closer).Close()
+		}
+	}
+	return err
+}
+
+// bindFileFields binds the file fields of destination, recording the fields
+// holding an opened file in opened.
+func bindFileFields(destination any, files map[string][]*multipart.FileHeader, limit int64, opened *[]reflect.Value) error {
+	typ := reflect.TypeOf(destination).Elem()
+	val := reflect.ValueOf(destination).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		typeField := typ.Field(i)
+		structField := val.Field(i)
+		if typeField.Anonymous && structField.Kind() == reflect.Ptr {
+			structField = structField.Elem()
+		}
+		if !structField.CanSet() {
+			continue
+		}
+		name, options, _ := strings.Cut(typeField.Tag.Get("form"), ",")
+		if !isFileType(structField.Type()) {
+			if name == "" && structField.Kind() == reflect.Struct {
+				if err := bindFileFields(structField.Addr().Interface(), files, limit, opened); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if name == "" {
+			continue
+		}
+
+		headers, exists := files[name]
+		if !exists {
+			for k, v := range files {
+				if strings.EqualFold(k, name) {
+					headers, exists = v, true
+					break
+				}
+			}
+		}
+		if len(headers) == 0 {
+			if hasTagOption(options, "required") {
+				return fmt.Errorf("missing required form file %q", name)
+			}
+			continue
+		}
+		for _, fh := range headers {
+			if limit > 0 && fh.Size > limit {
+				return ErrStatusRequestEntityTooLarge.WithInternal(
+					fmt.Errorf("slim: file %q exceeds %d bytes", fh.Filename, limit))
+			}
+		}
+
+		if u, ok := structField.Addr().Interface().(BindFileUnmarshaler); ok {
+			if err := u.UnmarshalFile(headers[0]); err != nil {
+				return err
+			}
+			continue
+		}
+		switch structField.Type() {
+		case fileHeaderType:
+			structField.Set(reflect.ValueOf(headers[0]))
+		case fileHeadersType:
+			structField.Set(reflect.ValueOf(headers))
+		default:
+			f, err := headers[0].Open()
+			if err != nil {
+				return err
+			}
+			structField.Set(reflect.ValueOf(f))
+			*opened = append(*opened, structField)
 		}
 	}
 	return nil
 }
 
+// isNestableType reports whether fields of type t may be bound from nested keys.
+func isNestableType(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(bindUnmarshalerType) {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// nestedData returns the inputs whose keys continue name with a dot or
+// bracket segment, keyed by the remainder, e.g. `[0].name` for `items[0].name`.
+func nestedData(data map[string][]string, name string) map[string][]string {
+	var nested map[string][]string
+	for k, v := range data {
+		if len(k) <= len(name) || !strings.EqualFold(k[:len(name)], name) {
+			continue
+		}
+		if rest := k[len(name):]; rest[0] == '.' || rest[0] == '[' {
+			if nested == nil {
+				nested = make(map[string][]string)
+			}
+			nested[rest] = append(nested[rest], v...)
+		}
+	}
+	return nested
+}
+
+// splitSegment splits `[seg]rest` or `.seg rest` into its first segment and
+// the remainder.
+func splitSegment(key string) (seg, rest string, ok bool) {
+	switch {
+	case strings.HasPrefix(key, "["):
+		i := strings.IndexByte(key, ']')
+		if i < 0 {
+			return "", "", false
+		}
+		return key[1:i], key[i+1:], true
+	case strings.HasPrefix(key, ".") && len(key) > 1:
+		key = key[1:]
+		if i := strings.IndexAny(key, ".["); i >= 0 {
+			return key[:i], key[i:], true
+		}
+		return key, "", true
+	}
+	return "", "", false
+}
+
+// groupSegments groups nested inputs by their first segment.
+func groupSegments(data map[string][]string) map[string]map[string][]string {
+	groups := make(map[string]map[string][]string)
+	for k, v := range data {
+		seg, rest, ok := splitSegment(k)
+		if !ok {
+			continue
+		}
+		group := groups[seg]
+		if group == nil {
+			group = make(map[string][]string)
+			groups[seg] = group
+		}
+		group[rest] = append(group[rest], v...)
+	}
+	return groups
+}
+
+// bindNested binds nested inputs, keyed by the remainder of their keys, into
+// a struct, slice or map value.
+func bindNested(field reflect.Value, data map[string][]string, tag string, depth bindDepth) error {
+	if depth.level > depth.maxDepth {
+		return fmt.Errorf("binding exceeds the maximum depth of %d", depth.maxDepth)
+	}
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+
+	switch field.Kind() {
+	case reflect.Struct:
+		values := make(map[string][]string, len(data))
+		for k, v := range data {
+			if seg, rest, ok := splitSegment(k); ok {
+				values[seg+rest] = append(values[seg+rest], v...)
+			}
+		}
+		return bindDataDepth(field.Addr().Interface(), values, tag, depth)
+
+	case reflect.Slice:
+		groups := groupSegments(data)
+		appended := groups[""][""]
+		delete(groups, "")
+		length := field.Len()
+		indexes := make(map[int]map[string][]string, len(groups))
+		for seg, group := range groups {
+			index, err := strconv.Atoi(seg)
+			if err != nil || index < 0 {
+				return fmt.Errorf("invalid index %q", seg)
+			}
+			if index > depth.maxIndex {
+				return fmt.Errorf("index %d exceeds the maximum of %d", index, depth.maxIndex)
+			}
+			indexes[index] = group
+			length = max(length, index+1)
+		}
+		if length+len(appended) > depth.maxIndex+1 {
+			return fmt.Errorf("binding exceeds the maximum of %d elements", depth.maxIndex+1)
+		}
+		slice := reflect.MakeSlice(field.Type(), length, length+len(appended))
+		reflect.Copy(slice, field)
+		for index, group := range indexes {
+			if err := bindElem(slice.Index(index), group, tag, depth); err != nil {
+				return err
+			}
+		}
+		for _, v := range appended {
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := setWithProperType(elem.Kind(), v, elem); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		field.Set(slice)
+
+	case reflect.Map:
+		if field.IsNil() {
+			field.Set(reflect.MakeMap(field.Type()))
+		}
+		typ := field.Type()
+		for seg, group := range groupSegments(data) {
+			key := reflect.New(typ.Key()).Elem()
+			if err := setWithProperType(key.Kind(), seg, key); err != nil {
+				return err
+			}
+			elem := reflect.New(typ.Elem()).Elem()
+			if existing := field.MapIndex(key); existing.IsValid() {
+				elem.Set(existing)
+			}
+			if err := bindElem(elem, group, tag, depth); err != nil {
+				return err
+			}
+			field.SetMapIndex(key, elem)
+		}
+	}
+	return nil
+}
+
+// bindElem binds the inputs of a single slice element or map value. Inputs
+// without a remainder are set directly, slices keep all repeated values.
+func bindElem(elem reflect.Value, data map[string][]string, tag string, depth bindDepth) error {
+	if values, ok := data[""]; ok && len(values) > 0 {
+		if elem.Kind() == reflect.Slice {
+			if _, ok := elem.Addr().Interface().(BindUnmarshaler); !ok {
+				slice := reflect.MakeSlice(elem.Type(), len(values), len(values))
+				for i, v := range values {
+					if err := setWithProperType(elem.Type().Elem().Kind(), v, slice.Index(i)); err != nil {
+						return err
+					}
+				}
+				elem.Set(slice)
+				return nil
+			}
+		}
+		return setWithProperType(elem.Kind(), values[0], elem)
+	}
+	delete(data, "")
+	if len(data) == 0 {
+		return nil
+	}
+	return bindNested(elem, data, tag, depth.next())
+}
+
+func isEmptyInput(values []string) bool {
+	for _, v := range values {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func hasTagOption(options, option string) bool {
+	for options != "" {
+		var o string
+		o, options, _ = strings.Cut(options, ",")
+		if strings.TrimSpace(o) == option {
+			return true
+		}
+	}
+	return false
+}
+
 func setWithProperType(valueKind reflect.Kind, val string, structField reflect.Value) error {
 	// But also call it here, in case we're dealing with an array of BindUnmarshalers
 	if ok, err := unmarshalField(valueKind, val, structField); ok {
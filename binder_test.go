@@ -1,7 +1,17 @@
 package slim
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -82,3 +92,308 @@ func TestBindData_NonStructWithFormTagErrors(t *testing.T) {
 		t.Fatalf("expected error when binding non-struct for form")
 	}
 }
+
+func TestBindData_Defaults(t *testing.T) {
+	type S struct {
+		Page int      `query:"page" default:"1"`
+		Size int      `query:"size" default:"20"`
+		Sort []string `query:"sort" default:"name,id"`
+		Q    string   `query:"q" default:"all"`
+	}
+	var dst S
+	data := map[string][]string{"size": {"50"}, "q": {""}}
+	if err := bindData(&dst, data, "query"); err != nil { t.Fatal(err) }
+	if dst.Page != 1 || dst.Size != 50 || dst.Q != "all" { t.Fatalf("dst=%+v", dst) }
+	if !reflect.DeepEqual(dst.Sort, []string{"name", "id"}) { t.Fatalf("Sort=%v", dst.Sort) }
+
+	// defaults apply even without any input, but never overwrite bound values
+	dst = S{Page: 3}
+	if err := bindData(&dst, nil, "query"); err != nil { t.Fatal(err) }
+	if dst.Page != 3 || dst.Size != 20 { t.Fatalf("dst=%+v", dst) }
+}
+
+func TestBindData_Required(t *testing.T) {
+	type S struct {
+		ID   int `query:"id,required"`
+		Page int `query:"page,required" default:"1"`
+	}
+	var dst S
+	err := bindData(&dst, map[string][]string{"page": {"2"}}, "query")
+	if err == nil || err.Error() != `missing required query parameter "id"` { t.Fatalf("err=%v", err) }
+	if err = bindData(&dst, map[string][]string{"id": {""}}, "query"); err == nil { t.Fatal("expected error for empty id") }
+	dst = S{}
+	if err = bindData(&dst, map[string][]string{"id": {"5"}}, "query"); err != nil { t.Fatal(err) }
+	if dst.ID != 5 || dst.Page != 1 { t.Fatalf("dst=%+v", dst) }
+}
+
+func TestBindData_Nested(t *testing.T) {
+	type Item struct {
+		Name string `form:"name"`
+		Qty  int    `form:"qty"`
+	}
+	type Address struct {
+		City string `form:"city"`
+		Zip  string `form:"zip"`
+	}
+	type S struct {
+		Items   []Item              `form:"items"`
+		Address *Address            `form:"address"`
+		Tags    []string            `form:"tags"`
+		IDs     []int               `form:"ids"`
+		Labels  map[string]string   `form:"labels"`
+		Multi   map[string][]string `form:"multi"`
+		Homes   map[string]Address  `form:"homes"`
+	}
+	var dst S
+	data := map[string][]string{
+		"items[0].name":    {"a"},
+		"items[0].qty":     {"2"},
+		"items[2][name]":   {"c"},
+		"address.city":     {"x"},
+		"address[zip]":     {"123"},
+		"tags[]":           {"t1", "t2"},
+		"ids[1]":           {"7"},
+		"labels[env]":      {"prod"},
+		"multi[k]":         {"1", "2"},
+		"homes[home].city": {"y"},
+	}
+	if err := bindData(&dst, data, "form"); err != nil { t.Fatal(err) }
+	if !reflect.DeepEqual(dst.Items, []Item{{"a", 2}, {}, {Name: "c"}}) { t.Fatalf("Items=%+v", dst.Items) }
+	if dst.Address == nil || *dst.Address != (Address{"x", "123"}) { t.Fatalf("Address=%+v", dst.Address) }
+	if !reflect.DeepEqual(dst.Tags, []string{"t1", "t2"}) { t.Fatalf("Tags=%v", dst.Tags) }
+	if !reflect.DeepEqual(dst.IDs, []int{0, 7}) { t.Fatalf("IDs=%v", dst.IDs) }
+	if dst.Labels["env"] != "prod" { t.Fatalf("Labels=%v", dst.Labels) }
+	if !reflect.DeepEqual(dst.Multi["k"], []string{"1", "2"}) { t.Fatalf("Multi=%v", dst.Multi) }
+	if dst.Homes["home"].City != "y" { t.Fatalf("Homes=%v", dst.Homes) }
+}
+
+func TestBindData_NestedLimits(t *testing.T) {
+	type S struct{ Tags []string `form:"tags"` }
+	var dst S
+	data := map[string][]string{"tags[100000000]": {"x"}}
+	if err := bindData(&dst, data, "form"); err == nil { t.Fatal("expected index limit error") }
+	if err := bindData(&dst, map[string][]string{"tags[x]": {"x"}}, "form"); err == nil { t.Fatal("expected invalid index error") }
+
+	type Node struct {
+		Name     string          `form:"name"`
+		Children map[string]Node `form:"c"`
+	}
+	var n Node
+	key := "c" + strings.Repeat("[a].c", DefaultMaxBindDepth) + "[a].name"
+	if err := bindData(&n, map[string][]string{key: {"deep"}}, "form"); err == nil { t.Fatal("expected depth limit error") }
+	if err := bindData(&n, map[string][]string{"c[a].c[b].name": {"ok"}}, "form"); err != nil { t.Fatal(err) }
+	if n.Children["a"].Children["b"].Name != "ok" { t.Fatalf("n=%+v", n) }
+}
+
+func TestBindQueryParams_OptionsLimits(t *testing.T) {
+	type S struct{ Tags []string `query:"tags"` }
+	s := New()
+	bind := func(target string, options *BindOptions) error {
+		c := s.NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+		if options != nil {
+			WithBindOptions(*options)(c, func(Context) error { return nil })
+		}
+		var dst S
+		return BindQueryParams(c, &dst)
+	}
+	if err := bind("/?tags[5]=x", nil); err != nil { t.Fatal(err) }
+	if err := bind("/?tags[5]=x", &BindOptions{MaxBindIndex: 4}); err == nil { t.Fatal("expected index limit error") }
+	if err := bind("/?tags[2000]=x", &BindOptions{MaxBindIndex: 5000}); err != nil { t.Fatal(err) }
+
+	s.BindOptions = BindOptions{MaxBindIndex: 2}
+	if err := bind("/?tags[3]=x", nil); err == nil { t.Fatal("expected index limit error from Slim.BindOptions") }
+}
+
+func TestBindData_MapKeepsRepeatedValues(t *testing.T) {
+	m := map[string][]string{}
+	if err := bindData(&m, map[string][]string{"k": {"a", "b"}}, "query"); err != nil { t.Fatal(err) }
+	if !reflect.DeepEqual(m["k"], []string{"a", "b"}) { t.Fatalf("m=%v", m) }
+}
+
+type avatarFile struct {
+	io.ReadCloser
+	Name string
+}
+
+func (a *avatarFile) UnmarshalFile(fh *multipart.FileHeader) (err error) {
+	a.Name = fh.Filename
+	a.ReadCloser, err = fh.Open()
+	return
+}
+
+func TestBindBody_MultipartFiles(t *testing.T) {
+	type Signup struct {
+		Name   string                  `form:"name"`
+		Header *multipart.FileHeader   `form:"file,required"`
+		All    []*multipart.FileHeader `form:"file"`
+		Reader io.ReadCloser           `form:"file"`
+		Avatar avatarFile              `form:"file"`
+		Other  *multipart.FileHeader   `form:"other"`
+	}
+	s := New()
+	r := newUploadRequest(t, map[string]string{"name": "bob"}, map[string][]byte{"a.png": pngHeader})
+	var dst Signup
+	if err := BindBody(s.NewContext(httptest.NewRecorder(), r), &dst); err != nil { t.Fatal(err) }
+	if dst.Name != "bob" || dst.Header == nil || dst.Header.Filename != "a.png" || len(dst.All) != 1 || dst.Other != nil { t.Fatalf("dst=%+v", dst) }
+	if dst.Reader == nil || dst.Avatar.Name != "a.png" { t.Fatalf("dst=%+v", dst) }
+	b, _ := io.ReadAll(dst.Avatar)
+	if !bytes.Equal(b, pngHeader) { t.Fatalf("avatar=%q", b) }
+	dst.Reader.Close()
+	dst.Avatar.Close()
+
+	// required file missing
+	r = newUploadRequest(t, map[string]string{"name": "bob"}, nil)
+	err := BindBody(s.NewContext(httptest.NewRecorder(), r), &Signup{})
+	if he, ok := err.(*HTTPError); !ok || he.Code != http.StatusBadRequest { t.Fatalf("err=%v", err) }
+
+	// file larger than the multipart limit
+	s.MultipartMemoryLimit = 4
+	r = newUploadRequest(t, nil, map[string][]byte{"a.png": pngHeader})
+	err = BindBody(s.NewContext(httptest.NewRecorder(), r), &Signup{})
+	if he, ok := err.(*HTTPError); !ok || he.Code != http.StatusRequestEntityTooLarge { t.Fatalf("err=%v", err) }
+}
+
+func TestBindFiles_ClosesOpenedFilesOnError(t *testing.T) {
+	type Upload struct {
+		Reader  io.ReadCloser         `form:"file"`
+		Missing *multipart.FileHeader `form:"missing,required"`
+	}
+	r := newUploadRequest(t, nil, map[string][]byte{"a.png": pngHeader})
+	// a tiny memory limit stores the file on disk, so Open returns an *os.File
+	if err := r.ParseMultipartForm(1); err != nil { t.Fatal(err) }
+	defer r.MultipartForm.RemoveAll()
+	var dst Upload
+	if err := bindFiles(&dst, r.MultipartForm.File, 0); err == nil { t.Fatal("expected missing file error") }
+	if dst.Reader == nil { t.Fatal("the first field must have been bound") }
+	if _, err := dst.Reader.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) { t.Fatalf("file not closed, read err=%v", err) }
+}
+
+func TestBindData_PlanIsCached(t *testing.T) {
+	type S struct {
+		A int    `query:"a"`
+		B string `query:"b"`
+	}
+	typ := reflect.TypeOf(S{})
+	if cachedBindPlan(typ, "query") != cachedBindPlan(typ, "query") { t.Fatal("plan not cached") }
+	if cachedBindPlan(typ, "query") == cachedBindPlan(typ, "form") { t.Fatal("plans must be per tag") }
+	var dst S
+	if err := bindData(&dst, map[string][]string{"A": {"1"}, "b": {"x"}}, "query"); err != nil { t.Fatal(err) }
+	if dst.A != 1 || dst.B != "x" { t.Fatalf("dst=%+v", dst) }
+}
+
+type orderedReq struct {
+	ID   int    `path:"id" json:"id"`
+	Name string `query:"name" json:"name"`
+}
+
+func (*orderedReq) BindOrder() []string { return []string{"body", "query", "path"} }
+
+func TestDefaultBinder_Sources(t *testing.T) {
+	s := New()
+	b := s.Binder.(*DefaultBinder)
+	b.RegisterSource("session", func(c Context) (map[string][]string, error) {
+		return map[string][]string{"user_id": {"42"}}, nil
+	})
+	type Req struct {
+		Token  string `header:"X-Token"`
+		Theme  string `cookie:"theme"`
+		UserID int    `session:"user_id"`
+		Page   int    `query:"page"`
+	}
+	r := httptest.NewRequest(http.MethodPost, "/?page=2", nil)
+	r.Header.Set("X-Token", "abc")
+	r.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	var dst Req
+	if err := b.Bind(s.NewContext(httptest.NewRecorder(), r), &dst); err != nil { t.Fatal(err) }
+	// query params are not bound by default for requests with a body
+	if dst != (Req{Token: "abc", Theme: "dark", UserID: 42}) { t.Fatalf("dst=%+v", dst) }
+
+	// headers, cookies and custom sources are never bound to maps
+	m := map[string]string{}
+	if err := b.Bind(s.NewContext(httptest.NewRecorder(), r), &m); err != nil { t.Fatal(err) }
+	if len(m) != 0 { t.Fatalf("m=%v", m) }
+}
+
+func TestDefaultBinder_BindOrder(t *testing.T) {
+	s := New()
+	s.POST("/users/:id", func(c Context) error {
+		var req orderedReq
+		if err := c.Bind(&req); err != nil { return err }
+		return c.String(http.StatusOK, strconv.Itoa(req.ID)+" "+req.Name)
+	})
+	r := httptest.NewRequest(http.MethodPost, "/users/7?name=query", strings.NewReader(`{"id":1,"name":"body"}`))
+	r.Header.Set(HeaderContentType, MIMEApplicationJSON)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Body.String() != "7 query" { t.Fatalf("body=%q", w.Body.String()) }
+
+	var bad struct{ A int `query:"a"` }
+	err := s.Binder.(*DefaultBinder).BindSource(s.NewContext(nil, r), &bad, "nope")
+	if err == nil { t.Fatal("expected unknown source error") }
+}
+
+func TestDefaultBinder_Order(t *testing.T) {
+	s := New()
+	b := &DefaultBinder{Order: []string{"body", "query", "header"}}
+	type Req struct {
+		Name  string `query:"name" json:"name"`
+		Token string `header:"X-Token"`
+	}
+	r := httptest.NewRequest(http.MethodPost, "/?name=query", strings.NewReader(`{"name":"body"}`))
+	r.Header.Set(HeaderContentType, MIMEApplicationJSON)
+	r.Header.Set("X-Token", "abc")
+	var dst Req
+	if err := b.Bind(s.NewContext(httptest.NewRecorder(), r), &dst); err != nil { t.Fatal(err) }
+	// query params are bound for any method, after the body
+	if dst != (Req{Name: "query", Token: "abc"}) { t.Fatalf("dst=%+v", dst) }
+
+	// headers are not bound to maps
+	r = httptest.NewRequest(http.MethodPost, "/?name=query", nil)
+	r.Header.Set("X-Token", "abc")
+	m := map[string]string{}
+	if err := b.Bind(s.NewContext(httptest.NewRecorder(), r), &m); err != nil { t.Fatal(err) }
+	if len(m) != 1 || m["name"] != "query" { t.Fatalf("m=%v", m) }
+}
+
+func TestBindBody_JSONOptions(t *testing.T) {
+	type Payload struct {
+		Name  string `json:"name"`
+		Extra any    `json:"extra"`
+	}
+	s := New()
+	bind := func(body string, setup func(c Context)) (Payload, error) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		c := s.NewContext(httptest.NewRecorder(), r)
+		if setup != nil {
+			setup(c)
+		}
+		var p Payload
+		return p, BindBody(c, &p)
+	}
+	status := func(err error) int {
+		if he, ok := err.(*HTTPError); ok { return he.Code }
+		return 0
+	}
+
+	// lenient by default
+	if _, err := bind(`{"name":"a","nme":"b"} {}`, nil); err != nil { t.Fatal(err) }
+
+	s.BindOptions = BindOptions{DisallowUnknownFields: true, DisallowTrailingData: true, UseNumber: true, MaxBodySize: 64}
+	p, err := bind(`{"name":"a","extra":12345678901234567890}`+"\n", nil)
+	if err != nil { t.Fatal(err) }
+	if n, ok := p.Extra.(json.Number); !ok || n.String() != "12345678901234567890" { t.Fatalf("extra=%#v", p.Extra) }
+
+	_, err = bind(`{"name":"a","nme":"b"}`, nil)
+	if status(err) != http.StatusBadRequest || !strings.Contains(err.(*HTTPError).Message.(string), "field=nme") { t.Fatalf("err=%v", err) }
+	if _, err = bind(`{"name":"a"} {}`, nil); status(err) != http.StatusBadRequest { t.Fatalf("err=%v", err) }
+	if _, err = bind(`{"name":"`+strings.Repeat("a", 100)+`"}`, nil); status(err) != http.StatusRequestEntityTooLarge { t.Fatalf("err=%v", err) }
+	// bodies of unknown length are cut off while reading
+	if _, err = bind(`{"name":"`+strings.Repeat("a", 100)+`"}`, func(c Context) { c.Request().ContentLength = -1 }); status(err) != http.StatusRequestEntityTooLarge { t.Fatalf("err=%v", err) }
+
+	// per route override
+	_, err = bind(`{"name":"`+strings.Repeat("a", 100)+`"}`, func(c Context) {
+		WithBindOptions(BindOptions{MaxBodySize: 1 << 10})(c, func(Context) error { return nil })
+	})
+	if err != nil { t.Fatal(err) }
+}
@@ -0,0 +1,188 @@
+package slim
+
+import (
+	"bytes"
+	"cmp"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DirectoryListing is the data of a directory listing, encoded as JSON or
+// rendered by the template named in `StaticConfig.BrowseTemplate`.
+type DirectoryListing struct {
+	// Path is the URL path of the directory, ending with a slash.
+	Path string `json:"path"`
+	// Sort is the sort key: "name", "size" or "time".
+	Sort string `json:"sort"`
+	// Order is the sort order: "asc" or "desc".
+	Order string `json:"order"`
+	// Entries lists the files of the directory, subdirectories first.
+	Entries []DirectoryEntry `json:"entries"`
+}
+
+// DirectoryEntry is a file of a DirectoryListing.
+type DirectoryEntry struct {
+	Name    string    `json:"name"`
+	URL     string    `json:"url"`
+	IsDir   bool      `json:"isDir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// serveDirectoryListing lists the directory name of fsys, as JSON when the
+// client prefers it and as HTML otherwise. The "sort" ("name", "size" or
+// "time") and "order" ("asc" or "desc") query parameters sort the entries.
+func serveDirectoryListing(c Context, fsys http.FileSystem, name string, config StaticConfig) error {
+	// relative links need the trailing slash
+	if p := c.Request().URL.Path; !strings.HasSuffix(p, "/") {
+		u := *c.Request().URL
+		u.Path += "/"
+		return c.Redirect(http.StatusMovedPermanently, u.RequestURI())
+	}
+
+	dir, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		return err
+	}
+
+	listing := DirectoryListing{
+		Path:    c.Request().URL.Path,
+		Sort:    c.QueryParam("sort"),
+		Order:   c.QueryParam("order"),
+		Entries: make([]DirectoryEntry, 0, len(infos)),
+	}
+	for _, info := range infos {
+		if (!config.ShowHidden || config.DenyHidden) && strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		entry := DirectoryEntry{
+			Name:    info.Name(),
+			URL:     (&url.URL{Path: info.Name()}).EscapedPath(),
+			IsDir:   info.IsDir(),
+			ModTime: info.ModTime(),
+		}
+		if entry.IsDir {
+			entry.URL += "/"
+		} else {
+			entry.Size = info.Size()
+		}
+		// a colon in the first segment would make the URL absolute
+		if strings.Contains(entry.Name, ":") {
+			entry.URL = "./" + entry.URL
+		}
+		listing.Entries = append(listing.Entries, entry)
+	}
+	sortDirectoryEntries(&listing)
+
+	if c.Accepts("html", "json") == "json" {
+		return c.JSON(http.StatusOK, listing)
+	}
+	if config.BrowseTemplate != "" {
+		return c.Render(http.StatusOK, config.BrowseTemplate, listing)
+	}
+	var buf bytes.Buffer
+	if err = directoryListingTemplate.Execute(&buf, listing); err != nil {
+		return err
+	}
+	return c.HTMLBlob(http.StatusOK, buf.Bytes())
+}
+
+func sortDirectoryEntries(listing *DirectoryListing) {
+	switch listing.Sort {
+	case "size", "time":
+	default:
+		listing.Sort = "name"
+	}
+	if listing.Order != "desc" {
+		listing.Order = "asc"
+	}
+	slices.SortStableFunc(listing.Entries, func(a, b DirectoryEntry) int {
+		if a.IsDir != b.IsDir {
+			if a.IsDir {
+				return -1
+			}
+			return 1
+		}
+		var n int
+		switch listing.Sort {
+		case "size":
+			n = cmp.Compare(a.Size, b.Size)
+		case "time":
+			n = a.ModTime.Compare(b.ModTime)
+		}
+		if n == 0 {
+			n = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		}
+		if listing.Order == "desc" {
+			n = -n
+		}
+		return n
+	})
+}
+
+var directoryListingTemplate = template.Must(template.New("listing").Funcs(template.FuncMap{
+	"sortURL": func(l DirectoryListing, key string) string {
+		order := "asc"
+		if l.Sort == key && l.Order == "asc" {
+			order = "desc"
+		}
+		return "?sort=" + key + "&order=" + order
+	},
+	"formatSize": formatFileSize,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Index of {{.Path}}</title>
+<style>
+body{font-family:system-ui,sans-serif;margin:2em}
+table{border-collapse:collapse}
+th,td{padding:.25em 1em;text-align:left}
+td.size{text-align:right}
+</style>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<thead><tr>
+<th><a href="{{sortURL . "name"}}">Name</a></th>
+<th><a href="{{sortURL . "size"}}">Size</a></th>
+<th><a href="{{sortURL . "time"}}">Modified</a></th>
+</tr></thead>
+<tbody>
+{{- if ne .Path "/"}}
+<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr><td><a href="{{.URL}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td class="size">{{if not .IsDir}}{{formatSize .Size}}{{end}}</td><td>{{.ModTime.UTC.Format "2006-01-02 15:04:05"}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// formatFileSize formats a file size with binary units, "1.5 KiB".
+func formatFileSize(size int64) string {
+	const units = "KMGTPE"
+	if size < 1024 {
+		return strconv.FormatInt(size, 10) + " B"
+	}
+	div, exp := int64(1024), 0
+	for n := size / 1024; n >= 1024 && exp < len(units)-1; n /= 1024 {
+		div *= 1024
+		exp++
+	}
+	return strconv.FormatFloat(float64(size)/float64(div), 'f', 1, 64) + " " + units[exp:exp+1] + "iB"
+}
@@ -0,0 +1,165 @@
+package slim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func newBrowseDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, "pub/b.txt", strings.Repeat("b", 2048))
+	writeFile(t, dir, "pub/a b.txt", "a")
+	writeFile(t, dir, "pub/c.txt", "cc")
+	writeFile(t, dir, "pub/.secret", "hidden")
+	writeFile(t, dir, "pub/sub/x.txt", "x")
+	now := time.Now()
+	for name, age := range map[string]time.Duration{"c.txt": time.Hour, "a b.txt": time.Minute} {
+		if err := os.Chtimes(filepath.Join(dir, "pub", name), now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func getListing(t *testing.T, s *Slim, target string) DirectoryListing {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.Header.Set(HeaderAccept, MIMEApplicationJSON)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: code=%d body=%q", target, w.Code, w.Body.String())
+	}
+	var listing DirectoryListing
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatal(err)
+	}
+	return listing
+}
+
+func entryNames(l DirectoryListing) string {
+	names := make([]string, len(l.Entries))
+	for i, e := range l.Entries {
+		names[i] = e.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestStatic_BrowseJSON(t *testing.T) {
+	dir := newBrowseDir(t)
+	s := New()
+	s.Use(StaticConfig{Root: dir, Browse: true}.ToMiddleware())
+
+	cases := []struct{ query, want string }{
+		{"", "sub,a b.txt,b.txt,c.txt"},
+		{"?order=desc", "sub,c.txt,b.txt,a b.txt"},
+		{"?sort=size&order=desc", "sub,b.txt,c.txt,a b.txt"},
+		{"?sort=time", "sub,c.txt,a b.txt,b.txt"},
+		{"?sort=bogus", "sub,a b.txt,b.txt,c.txt"},
+	}
+	for _, c := range cases {
+		l := getListing(t, s, "/pub/"+c.query)
+		if got := entryNames(l); got != c.want {
+			t.Errorf("%q: got %s, want %s", c.query, got, c.want)
+		}
+	}
+	l := getListing(t, s, "/pub/")
+	if l.Path != "/pub/" || l.Sort != "name" || l.Order != "asc" {
+		t.Fatalf("listing=%+v", l)
+	}
+	if e := l.Entries[1]; e.URL != "a%20b.txt" || e.Size != 1 || e.ModTime.IsZero() {
+		t.Fatalf("entry=%+v", e)
+	}
+	if e := l.Entries[0]; e.URL != "sub/" || !e.IsDir {
+		t.Fatalf("entry=%+v", e)
+	}
+
+	s = New()
+	s.Use(StaticConfig{Root: dir, Browse: true, ShowHidden: true}.ToMiddleware())
+	if got := entryNames(getListing(t, s, "/pub/")); got != "sub,.secret,a b.txt,b.txt,c.txt" {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestStatic_BrowseHTML(t *testing.T) {
+	dir := newBrowseDir(t)
+	s := New()
+	s.Use(StaticConfig{Root: dir, Browse: true}.ToMiddleware())
+
+	r := httptest.NewRequest(http.MethodGet, "/pub/", nil)
+	r.Header.Set(HeaderAccept, "text/html,application/xhtml+xml,*/*;q=0.8")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get(HeaderContentType), MIMETextHTML) {
+		t.Fatalf("code=%d headers=%v", w.Code, w.Header())
+	}
+	for _, want := range []string{`<a href="a%20b.txt">a b.txt</a>`, `<a href="sub/">sub/</a>`, "2.0 KiB", `<a href="../">`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %q", want)
+		}
+	}
+	if strings.Contains(body, ".secret") {
+		t.Error("hidden file listed")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/pub?sort=size", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusMovedPermanently || w.Header().Get(HeaderLocation) != "/pub/?sort=size" {
+		t.Fatalf("redirect: code=%d location=%q", w.Code, w.Header().Get(HeaderLocation))
+	}
+
+	s = New()
+	s.Use(Static(dir))
+	r = httptest.NewRequest(http.MethodGet, "/pub/", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("browse disabled: code=%d", w.Code)
+	}
+}
+
+func TestStaticDirectoryHandler_Browse(t *testing.T) {
+	dir := newBrowseDir(t)
+	s := New()
+	s.Filesystem = os.DirFS(dir)
+	s.Renderer = &TemplateRenderer{
+		Template: template.Must(template.New("dir").Parse(`{{.Path}}:{{range .Entries}} {{.Name}}{{end}}`)),
+	}
+	s.GET("/files/*", StaticDirectoryHandlerWithConfig(StaticConfig{Root: "pub", Browse: true, BrowseTemplate: "dir"}))
+	s.GET("/plain/*", StaticDirectoryHandler("pub", false))
+
+	r := httptest.NewRequest(http.MethodGet, "/files/sub/", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "/files/sub/: x.txt" {
+		t.Fatalf("code=%d body=%q", w.Code, w.Body.String())
+	}
+	if l := getListing(t, s, "/files/sub/"); entryNames(l) != "x.txt" || l.Entries[0].Size != 1 {
+		t.Fatalf("listing=%+v", l)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/plain/sub/", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("browse disabled: code=%d", w.Code)
+	}
+}
+
+func TestFormatFileSize(t *testing.T) {
+	for size, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 5 << 20: "5.0 MiB", 3 << 40: "3.0 TiB"} {
+		if got := formatFileSize(size); got != want {
+			t.Errorf("formatFileSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
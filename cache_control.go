@@ -0,0 +1,296 @@
+package slim
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheControl is a builder for the `Cache-Control` response header. Every
+// call updates the header it is bound to, so there is no terminal method:
+//
+//	c.CacheControl().Public().MaxAge(time.Hour).StaleWhileRevalidate(time.Minute)
+//
+// Durations are rounded down to whole seconds, negative durations are
+// treated as zero.
+type CacheControl struct {
+	header http.Header
+
+	public          bool
+	private         bool
+	noCache         bool
+	noStore         bool
+	noTransform     bool
+	mustRevalidate  bool
+	proxyRevalidate bool
+	immutable       bool
+
+	// the following are -1 when not set
+	maxAge               int64
+	sMaxAge              int64
+	staleWhileRevalidate int64
+	staleIfError         int64
+
+	// extensions holds the directives the builder does not know, kept as is
+	extensions []string
+}
+
+// NewCacheControl returns a `Cache-Control` builder bound to the given header.
+// The builder starts from the existing `Cache-Control` value, so directives
+// set earlier, by a middleware for instance, are kept; delete the header
+// first to start over.
+func NewCacheControl(header http.Header) *CacheControl {
+	cc := &CacheControl{
+		header:               header,
+		maxAge:               -1,
+		sMaxAge:              -1,
+		staleWhileRevalidate: -1,
+		staleIfError:         -1,
+	}
+	if header != nil {
+		for _, value := range header.Values(HeaderCacheControl) {
+			cc.parse(value)
+		}
+	}
+	return cc
+}
+
+// parse loads the directives of a `Cache-Control` header value.
+func (cc *CacheControl) parse(value string) {
+	for _, directive := range splitDirectives(value) {
+		name, arg, hasArg := strings.Cut(directive, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if hasArg {
+			var target *int64
+			switch name {
+			case "max-age":
+				target = &cc.maxAge
+			case "s-maxage":
+				target = &cc.sMaxAge
+			case "stale-while-revalidate":
+				target = &cc.staleWhileRevalidate
+			case "stale-if-error":
+				target = &cc.staleIfError
+			}
+			n, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(arg), `"`), 10, 64)
+			if target != nil && err == nil && n >= 0 {
+				*target = n
+			} else {
+				cc.extensions = append(cc.extensions, directive)
+			}
+			continue
+		}
+		switch name {
+		case "public":
+			cc.public = true
+		case "private":
+			cc.private = true
+		case "no-cache":
+			cc.noCache = true
+		case "no-store":
+			cc.noStore = true
+		case "no-transform":
+			cc.noTransform = true
+		case "must-revalidate":
+			cc.mustRevalidate = true
+		case "proxy-revalidate":
+			cc.proxyRevalidate = true
+		case "immutable":
+			cc.immutable = true
+		default:
+			cc.extensions = append(cc.extensions, directive)
+		}
+	}
+}
+
+// splitDirectives splits a `Cache-Control` value on the commas outside of
+// quoted strings, such as in `no-cache="Set-Cookie, Set-Cookie2"`.
+func splitDirectives(value string) []string {
+	var directives []string
+	start, quoted := 0, false
+	for i := 0; i <= len(value); i++ {
+		if i < len(value) {
+			switch value[i] {
+			case '"':
+				quoted = !quoted
+				continue
+			case '\\':
+				if quoted && i+1 < len(value) {
+					i++
+				}
+				continue
+			case ',':
+				if quoted && i+1 < len(value) {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if d := strings.TrimSpace(value[start:min(i, len(value))]); d != "" {
+			directives = append(directives, d)
+		}
+		start = i + 1
+	}
+	return directives
+}
+
+// Public marks the response as storable by any cache, including shared ones.
+func (cc *CacheControl) Public() *CacheControl {
+	cc.public, cc.private = true, false
+	return cc.apply()
+}
+
+// Private marks the response as storable only by the user agent's cache.
+func (cc *CacheControl) Private() *CacheControl {
+	cc.private, cc.public = true, false
+	return cc.apply()
+}
+
+// NoCache requires caches to revalidate the response before each reuse.
+func (cc *CacheControl) NoCache() *CacheControl {
+	cc.noCache = true
+	return cc.apply()
+}
+
+// NoStore forbids caches from storing the response at all.
+func (cc *CacheControl) NoStore() *CacheControl {
+	cc.noStore = true
+	return cc.apply()
+}
+
+// NoTransform forbids intermediaries from transforming the response body.
+func (cc *CacheControl) NoTransform() *CacheControl {
+	cc.noTransform = true
+	return cc.apply()
+}
+
+// MustRevalidate forbids caches from serving the response once it is stale.
+func (cc *CacheControl) MustRevalidate() *CacheControl {
+	cc.mustRevalidate = true
+	return cc.apply()
+}
+
+// ProxyRevalidate is MustRevalidate for shared caches only.
+func (cc *CacheControl) ProxyRevalidate() *CacheControl {
+	cc.proxyRevalidate = true
+	return cc.apply()
+}
+
+// Immutable indicates that the response will not change while it is fresh.
+func (cc *CacheControl) Immutable() *CacheControl {
+	cc.immutable = true
+	return cc.apply()
+}
+
+// MaxAge sets how long the response stays fresh.
+func (cc *CacheControl) MaxAge(d time.Duration) *CacheControl {
+	cc.maxAge = seconds(d)
+	return cc.apply()
+}
+
+// SMaxAge sets how long the response stays fresh in shared caches.
+func (cc *CacheControl) SMaxAge(d time.Duration) *CacheControl {
+	cc.sMaxAge = seconds(d)
+	return cc.apply()
+}
+
+// StaleWhileRevalidate allows caches to serve a stale response for the given
+// duration while they revalidate it in the background.
+func (cc *CacheControl) StaleWhileRevalidate(d time.Duration) *CacheControl {
+	cc.staleWhileRevalidate = seconds(d)
+	return cc.apply()
+}
+
+// StaleIfError allows caches to serve a stale response for the given duration
+// when revalidation fails.
+func (cc *CacheControl) StaleIfError(d time.Duration) *CacheControl {
+	cc.staleIfError = seconds(d)
+	return cc.apply()
+}
+
+// String returns the header value.
+func (cc *CacheControl) String() string {
+	var directives []string
+	flag := func(on bool, name string) {
+		if on {
+			directives = append(directives, name)
+		}
+	}
+	value := func(v int64, name string) {
+		if v >= 0 {
+			directives = append(directives, name+"="+strconv.FormatInt(v, 10))
+		}
+	}
+	flag(cc.public, "public")
+	flag(cc.private, "private")
+	flag(cc.noCache, "no-cache")
+	flag(cc.noStore, "no-store")
+	flag(cc.noTransform, "no-transform")
+	value(cc.maxAge, "max-age")
+	value(cc.sMaxAge, "s-maxage")
+	value(cc.staleWhileRevalidate, "stale-while-revalidate")
+	value(cc.staleIfError, "stale-if-error")
+	flag(cc.mustRevalidate, "must-revalidate")
+	flag(cc.proxyRevalidate, "proxy-revalidate")
+	flag(cc.immutable, "immutable")
+	directives = append(directives, cc.extensions...)
+	return strings.Join(directives, ", ")
+}
+
+func (cc *CacheControl) apply() *CacheControl {
+	if cc.header != nil {
+		cc.header.Set(HeaderCacheControl, cc.String())
+	}
+	return cc
+}
+
+func seconds(d time.Duration) int64 {
+	if d < 0 {
+		return 0
+	}
+	return int64(d / time.Second)
+}
+
+// AppendVary adds the given header names to the `Vary` header, skipping names
+// that are already listed (case-insensitively). A `Vary: *` makes any further
+// names redundant.
+func AppendVary(header http.Header, names ...string) {
+	values := header.Values(HeaderVary)
+	fields := make([]string, 0, len(values)+len(names))
+	changed := len(values) > 1
+	add := func(field string) bool {
+		if field = strings.TrimSpace(field); field == "" {
+			return false
+		}
+		for _, f := range fields {
+			if strings.EqualFold(f, field) {
+				return false
+			}
+		}
+		fields = append(fields, field)
+		return true
+	}
+	for _, value := range values {
+		for field := range strings.SplitSeq(value, ",") {
+			if !add(field) && strings.TrimSpace(field) != "" {
+				changed = true
+			}
+		}
+	}
+	for _, name := range names {
+		if add(http.CanonicalHeaderKey(strings.TrimSpace(name))) {
+			changed = true
+		}
+	}
+	for _, field := range fields {
+		if field == "*" {
+			header.Set(HeaderVary, "*")
+			return
+		}
+	}
+	if changed {
+		header.Set(HeaderVary, strings.Join(fields, ", "))
+	}
+}
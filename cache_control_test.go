@@ -0,0 +1,85 @@
+package slim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheControl_Builder(t *testing.T) {
+	h := http.Header{}
+	NewCacheControl(h).Private().Public().MaxAge(time.Hour).SMaxAge(90 * time.Second).
+		StaleWhileRevalidate(time.Minute).Immutable()
+	want := "public, max-age=3600, s-maxage=90, stale-while-revalidate=60, immutable"
+	if got := h.Get(HeaderCacheControl); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	h = http.Header{}
+	NewCacheControl(h).NoStore().MaxAge(-time.Second)
+	if got := h.Get(HeaderCacheControl); got != "no-store, max-age=0" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestAppendVary_Deduplicates(t *testing.T) {
+	h := http.Header{}
+	h.Add(HeaderVary, "Origin")
+	h.Add(HeaderVary, "accept-encoding, Origin")
+	AppendVary(h, "Origin", "Accept-Encoding", "accept")
+	if got := h.Values(HeaderVary); len(got) != 1 || got[0] != "Origin, accept-encoding, Accept" {
+		t.Fatalf("got %q", got)
+	}
+
+	AppendVary(h, "*")
+	AppendVary(h, "Cookie")
+	if got := h.Get(HeaderVary); got != "*" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestContext_AcceptsAddsVary(t *testing.T) {
+	s := New()
+	s.GET("/", func(c Context) error {
+		c.Accepts("json", "xml")
+		c.Accepts("json")
+		c.AcceptsEncodings("gzip")
+		c.Vary(HeaderOrigin)
+		c.CacheControl().Private().NoCache()
+		return c.NoContent(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := w.Header().Get(HeaderVary); got != "Accept, Accept-Encoding, Origin" {
+		t.Fatalf("vary=%q", got)
+	}
+	if got := w.Header().Get(HeaderCacheControl); got != "private, no-cache" {
+		t.Fatalf("cache-control=%q", got)
+	}
+}
+
+func TestCacheControl_KeepsExistingDirectives(t *testing.T) {
+	h := http.Header{}
+	h.Set(HeaderCacheControl, `public, no-cache="Set-Cookie, Set-Cookie2", max-age=60, must-understand`)
+	NewCacheControl(h).MaxAge(time.Hour).Immutable()
+	want := `public, max-age=3600, immutable, no-cache="Set-Cookie, Set-Cookie2", must-understand`
+	if got := h.Get(HeaderCacheControl); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	s := New()
+	s.Use(func(c Context, next HandlerFunc) error {
+		c.CacheControl().Public()
+		return next(c)
+	})
+	s.GET("/", func(c Context) error {
+		c.CacheControl().MaxAge(time.Minute)
+		return c.NoContent(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := w.Header().Get(HeaderCacheControl); got != "public, max-age=60" {
+		t.Fatalf("cache-control=%q", got)
+	}
+}
@@ -0,0 +1,1106 @@
+package slim
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// CBORCodec implements Codec for CBOR, see RFC 8949.
+//
+// Struct fields are named by their `cbor` tag, then by their `json` tag, then
+// by the field name; both tags support "-" and the "omitempty" option.
+// `time.Time` is encoded with tag 1 (epoch seconds) when it has no fraction
+// of a second and with tag 0 (RFC 3339 text) otherwise. `big.Int` values are
+// encoded as plain integers when they fit, and as bignums (tags 2 and 3)
+// otherwise. Other tags and simple values are represented by CBORTag and
+// CBORSimple.
+//
+// With Deterministic set, the encoder follows the core deterministic encoding
+// requirements of RFC 8949 section 4.2.1: map keys are sorted by their
+// encoded bytes and floating point values use their shortest exact form.
+// Integers and lengths always use their shortest form and lengths are always
+// definite.
+//
+// Decoding accepts indefinite lengths. Decoding into an `any` value yields
+// nil, bool, int64 (uint64 or *big.Int for values out of its range), float64,
+// string, []byte, []any, map[string]any (map[any]any when a key is not a
+// string), time.Time, *big.Int, CBORTag or CBORSimple.
+type CBORCodec struct {
+	Deterministic bool
+}
+
+// CBORTag is a CBOR tagged value whose tag number has no built-in meaning.
+type CBORTag struct {
+	Number  uint64
+	Content any
+}
+
+// CBORSimple is a CBOR simple value other than false, true, null and
+// undefined.
+type CBORSimple uint8
+
+// cborMaxDepth limits the nesting of encoded and decoded values, it protects
+// against cyclic values and deeply nested input.
+const cborMaxDepth = 1000
+
+// CBOR major types
+const (
+	cborUint byte = iota
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// cborIndefinite is the additional information of an indefinite length
+// item, and of the "break" stop code when the major type is cborSimple.
+const cborIndefinite = 31
+
+var cborMajorNames = [...]string{"unsigned integer", "negative integer", "byte string", "text string", "array", "map", "tag", "simple value"}
+
+var (
+	cborTagType    = reflect.TypeOf(CBORTag{})
+	cborSimpleType = reflect.TypeOf(CBORSimple(0))
+	bigIntType     = reflect.TypeOf(big.Int{})
+	errCBORDepth   = errors.New("cbor: maximum nesting depth exceeded")
+)
+
+// Encode writes v as a single CBOR data item, indent is ignored.
+func (c CBORCodec) Encode(w io.Writer, v any, _ string) error {
+	e := cborEncoder{buf: make([]byte, 0, 256), deterministic: c.Deterministic}
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return err
+	}
+	_, err := w.Write(e.buf)
+	return err
+}
+
+// Decode reads a single CBOR data item from r into v, which must be a
+// non-nil pointer.
+func (CBORCodec) Decode(r io.Reader, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cbor: decode into non-pointer or nil %T", v)
+	}
+	br, ok := r.(codecReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	d := cborDecoder{r: br}
+	return d.decode(rv.Elem(), 0)
+}
+
+type cborEncoder struct {
+	buf           []byte
+	deterministic bool
+}
+
+// head writes the initial byte of a data item and its argument in the
+// shortest form.
+func (e *cborEncoder) head(major byte, n uint64) {
+	m := major << 5
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, m|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, m|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, m|25)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, m|26)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, m|27)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+func (e *cborEncoder) encode(v reflect.Value, depth int) error {
+	if depth > cborMaxDepth {
+		return errCBORDepth
+	}
+	if !v.IsValid() {
+		e.buf = append(e.buf, 0xf6)
+		return nil
+	}
+	switch v.Type() {
+	case timeType:
+		e.encodeTime(v.Interface().(time.Time))
+		return nil
+	case bigIntType:
+		if v.CanAddr() {
+			e.encodeBigInt(v.Addr().Interface().(*big.Int))
+		} else {
+			n := v.Interface().(big.Int)
+			e.encodeBigInt(&n)
+		}
+		return nil
+	case cborTagType:
+		tag := v.Interface().(CBORTag)
+		e.head(cborTag, tag.Number)
+		return e.encode(reflect.ValueOf(tag.Content), depth+1)
+	case cborSimpleType:
+		if n := v.Uint(); n < 24 {
+			e.buf = append(e.buf, 0xe0|byte(n))
+		} else {
+			e.buf = append(e.buf, 0xf8, byte(n))
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xf5)
+		} else {
+			e.buf = append(e.buf, 0xf4)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := v.Int(); i >= 0 {
+			e.head(cborUint, uint64(i))
+		} else {
+			e.head(cborNegInt, uint64(-1-i))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.head(cborUint, v.Uint())
+	case reflect.Float32:
+		if e.deterministic {
+			e.encodeShortestFloat(v.Float())
+		} else {
+			e.buf = append(e.buf, 0xfa)
+			e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(v.Float())))
+		}
+	case reflect.Float64:
+		if e.deterministic {
+			e.encodeShortestFloat(v.Float())
+		} else {
+			e.buf = append(e.buf, 0xfb)
+			e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+		}
+	case reflect.String:
+		e.head(cborText, uint64(v.Len()))
+		e.buf = append(e.buf, v.String()...)
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xf6)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.head(cborBytes, uint64(v.Len()))
+			e.buf = append(e.buf, v.Bytes()...)
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.head(cborBytes, uint64(v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				e.buf = append(e.buf, byte(v.Index(i).Uint()))
+			}
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xf6)
+			return nil
+		}
+		return e.encodeMap(v, depth)
+	case reflect.Struct:
+		return e.encodeStruct(v, depth)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xf6)
+			return nil
+		}
+		return e.encode(v.Elem(), depth+1)
+	default:
+		return fmt.Errorf("cbor: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// encodeShortestFloat writes f in the shortest of the half, single and
+// double precision formats that holds it exactly. NaN is written as the
+// canonical half precision quiet NaN.
+func (e *cborEncoder) encodeShortestFloat(f float64) {
+	if math.IsNaN(f) {
+		e.buf = append(e.buf, 0xf9, 0x7e, 0x00)
+		return
+	}
+	if h, ok := float16Bits(f); ok {
+		e.buf = append(e.buf, 0xf9)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, h)
+		return
+	}
+	if float64(float32(f)) == f {
+		e.buf = append(e.buf, 0xfa)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(f)))
+		return
+	}
+	e.buf = append(e.buf, 0xfb)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(f))
+}
+
+func (e *cborEncoder) encodeArray(v reflect.Value, depth int) error {
+	e.head(cborArray, uint64(v.Len()))
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cborEntry locates an encoded map entry in the encoder buffer.
+type cborEntry struct {
+	start, value, end int
+}
+
+// sortEntries reorders the entries written since start by their encoded
+// keys, as required by the deterministic encoding. Entries with equal keys
+// are ordered by their values, so the output does not depend on the map
+// iteration order.
+func (e *cborEncoder) sortEntries(start int, entries []cborEntry) {
+	body := bytes.Clone(e.buf[start:])
+	key := func(x cborEntry) []byte { return body[x.start-start : x.value-start] }
+	entry := func(x cborEntry) []byte { return body[x.start-start : x.end-start] }
+	sort.Slice(entries, func(i, j int) bool {
+		if c := bytes.Compare(key(entries[i]), key(entries[j])); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(entry(entries[i]), entry(entries[j])) < 0
+	})
+	e.buf = e.buf[:start]
+	for _, x := range entries {
+		e.buf = append(e.buf, entry(x)...)
+	}
+}
+
+func (e *cborEncoder) encodeMap(v reflect.Value, depth int) error {
+	e.head(cborMap, uint64(v.Len()))
+	keys := v.MapKeys()
+	if !e.deterministic && v.Type().Key().Kind() == reflect.String {
+		// sorted keys keep the default output stable, like encoding/json
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	}
+	start := len(e.buf)
+	entries := make([]cborEntry, 0, len(keys))
+	for _, k := range keys {
+		x := cborEntry{start: len(e.buf)}
+		if err := e.encode(k, depth+1); err != nil {
+			return err
+		}
+		x.value = len(e.buf)
+		if err := e.encode(v.MapIndex(k), depth+1); err != nil {
+			return err
+		}
+		x.end = len(e.buf)
+		entries = append(entries, x)
+	}
+	if e.deterministic {
+		e.sortEntries(start, entries)
+	}
+	return nil
+}
+
+func (e *cborEncoder) encodeStruct(v reflect.Value, depth int) error {
+	fields := codecFields(v.Type(), "cbor")
+	values := make([]reflect.Value, len(fields))
+	n := 0
+	for i, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		values[i] = fv
+		n++
+	}
+	e.head(cborMap, uint64(n))
+	start := len(e.buf)
+	entries := make([]cborEntry, 0, n)
+	for i, f := range fields {
+		if !values[i].IsValid() {
+			continue
+		}
+		x := cborEntry{start: len(e.buf)}
+		e.head(cborText, uint64(len(f.name)))
+		e.buf = append(e.buf, f.name...)
+		x.value = len(e.buf)
+		if err := e.encode(values[i], depth+1); err != nil {
+			return err
+		}
+		x.end = len(e.buf)
+		entries = append(entries, x)
+	}
+	if e.deterministic {
+		e.sortEntries(start, entries)
+	}
+	return nil
+}
+
+// encodeTime writes t as epoch seconds (tag 1) when it has no fraction of a
+// second, and as RFC 3339 text (tag 0) otherwise so no precision is lost.
+// Years RFC 3339 cannot represent fall back to whole epoch seconds.
+func (e *cborEncoder) encodeTime(t time.Time) {
+	t = t.UTC()
+	if t.Nanosecond() == 0 || t.Year() < 0 || t.Year() > 9999 {
+		e.buf = append(e.buf, 0xc1)
+		if sec := t.Unix(); sec >= 0 {
+			e.head(cborUint, uint64(sec))
+		} else {
+			e.head(cborNegInt, uint64(-1-sec))
+		}
+		return
+	}
+	s := t.Format(time.RFC3339Nano)
+	e.buf = append(e.buf, 0xc0)
+	e.head(cborText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// encodeBigInt writes n as an integer when it fits in 64 bits and as a
+// bignum otherwise.
+func (e *cborEncoder) encodeBigInt(n *big.Int) {
+	major, m := cborUint, n
+	if n.Sign() < 0 {
+		// a negative integer encodes -1-n
+		major, m = cborNegInt, new(big.Int).Not(n)
+	}
+	if m.IsUint64() {
+		e.head(major, m.Uint64())
+		return
+	}
+	e.head(cborTag, 2+uint64(major))
+	b := m.Bytes()
+	e.head(cborBytes, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// float16Bits returns the half precision bits of f, ok is false when f is
+// not exactly representable in half precision. f must not be NaN.
+func float16Bits(f float64) (h uint16, ok bool) {
+	f32 := float32(f)
+	if float64(f32) != f {
+		return 0, false
+	}
+	bits := math.Float32bits(f32)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+	switch {
+	case exp == 0xff: // infinity
+		return sign | 0x7c00, true
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0: // float32 subnormals are below the half precision range
+		return 0, false
+	}
+	exp -= 127
+	switch {
+	case exp >= -14 && exp <= 15:
+		if mant&(1<<13-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(exp+15)<<10 | uint16(mant>>13), true
+	case exp >= -24 && exp < -14:
+		// subnormal: the value is m * 2^-24
+		full := mant | 1<<23
+		shift := uint(-(exp + 1))
+		if full&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(full>>shift), true
+	}
+	return 0, false
+}
+
+// float16Value converts half precision bits to a float64.
+func float16Value(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+type cborDecoder struct {
+	r       codecReader
+	scratch [8]byte
+}
+
+// cborHead is the initial byte of a data item and its argument.
+type cborHead struct {
+	major byte
+	info  byte
+	arg   uint64
+}
+
+func (h cborHead) indefinite() bool { return h.info == cborIndefinite }
+
+func (h cborHead) isBreak() bool { return h.major == cborSimple && h.info == cborIndefinite }
+
+func (d *cborDecoder) readByte() (byte, error) {
+	c, err := d.r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return c, err
+}
+
+func (d *cborDecoder) readUint(size int) (uint64, error) {
+	b := d.scratch[:size]
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+func (d *cborDecoder) readHead() (cborHead, error) {
+	c, err := d.readByte()
+	if err != nil {
+		return cborHead{}, err
+	}
+	h := cborHead{major: c >> 5, info: c & 0x1f}
+	switch {
+	case h.info < 24:
+		h.arg = uint64(h.info)
+	case h.info <= 27:
+		h.arg, err = d.readUint(1 << (h.info - 24))
+	case h.info == cborIndefinite:
+		switch h.major {
+		case cborBytes, cborText, cborArray, cborMap, cborSimple:
+		default:
+			return h, fmt.Errorf("cbor: invalid indefinite length %s", cborMajorNames[h.major])
+		}
+	default:
+		return h, fmt.Errorf("cbor: reserved additional information %d", h.info)
+	}
+	if err == nil && h.major == cborSimple && h.info == 24 && h.arg < 32 {
+		err = fmt.Errorf("cbor: invalid simple value %d", h.arg)
+	}
+	return h, err
+}
+
+// length returns the definite length of a string, array or map.
+func (h cborHead) length() (int, error) {
+	if h.arg > math.MaxInt32 {
+		return 0, fmt.Errorf("cbor: length %d is too large", h.arg)
+	}
+	return int(h.arg), nil
+}
+
+// readBytes reads n bytes. Large lengths are read incrementally, so a
+// forged length does not allocate more memory than the input holds.
+func (d *cborDecoder) readBytes(n int) ([]byte, error) {
+	if n <= 64<<10 {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(d.r, b); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return b, nil
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readString reads the content of a byte or text string, joining the chunks
+// of an indefinite length string.
+func (d *cborDecoder) readString(h cborHead) ([]byte, error) {
+	if !h.indefinite() {
+		n, err := h.length()
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(n)
+	}
+	var b []byte
+	for {
+		ch, err := d.readHead()
+		if err != nil {
+			return nil, err
+		}
+		if ch.isBreak() {
+			if b == nil {
+				b = []byte{}
+			}
+			return b, nil
+		}
+		if ch.major != h.major || ch.indefinite() {
+			return nil, fmt.Errorf("cbor: invalid chunk in indefinite length %s", cborMajorNames[h.major])
+		}
+		chunk, err := d.readString(ch)
+		if err != nil {
+			return nil, err
+		}
+		if len(b)+len(chunk) > math.MaxInt32 {
+			return nil, errors.New("cbor: indefinite length string is too large")
+		}
+		b = append(b, chunk...)
+	}
+}
+
+// float returns the value of a floating point simple value.
+func (h cborHead) float() (float64, bool) {
+	switch h.info {
+	case 25:
+		return float16Value(uint16(h.arg)), true
+	case 26:
+		return float64(math.Float32frombits(uint32(h.arg))), true
+	case 27:
+		return math.Float64frombits(h.arg), true
+	}
+	return 0, false
+}
+
+// each calls fn for every element of an array, or every key of a map, whose
+// head is h; fn decodes the element (and for maps the value).
+func (d *cborDecoder) each(h cborHead, fn func(elem cborHead) error) error {
+	n := 0
+	if !h.indefinite() {
+		var err error
+		if n, err = h.length(); err != nil {
+			return err
+		}
+	}
+	for i := 0; h.indefinite() || i < n; i++ {
+		eh, err := d.readHead()
+		if err != nil {
+			return err
+		}
+		if eh.isBreak() {
+			if h.indefinite() {
+				return nil
+			}
+			return errors.New("cbor: unexpected break")
+		}
+		if err := fn(eh); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *cborDecoder) decode(v reflect.Value, depth int) error {
+	if depth > cborMaxDepth {
+		return errCBORDepth
+	}
+	h, err := d.readHead()
+	if err != nil {
+		return err
+	}
+	return d.decodeValue(h, v, depth)
+}
+
+func (d *cborDecoder) typeError(h cborHead, t reflect.Type) error {
+	return fmt.Errorf("cbor: cannot decode %s into %s", cborMajorNames[h.major], t)
+}
+
+func (d *cborDecoder) decodeValue(h cborHead, v reflect.Value, depth int) error {
+	if depth > cborMaxDepth {
+		return errCBORDepth
+	}
+	if h.isBreak() {
+		return errors.New("cbor: unexpected break")
+	}
+	if h.major == cborSimple && (h.info == 22 || h.info == 23) { // null, undefined
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeValue(h, v.Elem(), depth+1)
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			x, err := d.decodeAny(h, depth)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(&x).Elem())
+			return nil
+		}
+		if !v.IsNil() && v.Elem().Kind() == reflect.Ptr {
+			return d.decodeValue(h, v.Elem(), depth+1)
+		}
+		return d.typeError(h, v.Type())
+	}
+
+	switch v.Type() {
+	case timeType:
+		t, err := d.decodeTime(h, depth)
+		if err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return err
+	case bigIntType:
+		n, err := d.decodeBigInt(h)
+		if err == nil {
+			v.Set(reflect.ValueOf(n).Elem())
+		}
+		return err
+	case cborTagType:
+		if h.major != cborTag {
+			return d.typeError(h, v.Type())
+		}
+		x, err := d.decodeNextAny(depth + 1)
+		if err == nil {
+			v.Set(reflect.ValueOf(CBORTag{Number: h.arg, Content: x}))
+		}
+		return err
+	case cborSimpleType:
+		if h.major != cborSimple || h.info > 24 {
+			return d.typeError(h, v.Type())
+		}
+		v.SetUint(h.arg)
+		return nil
+	}
+
+	switch h.major {
+	case cborUint:
+		return setCodecInt("cbor", v, 0, h.arg, false)
+	case cborNegInt:
+		if h.arg > math.MaxInt64 {
+			return fmt.Errorf("cbor: -1-%d overflows %s", h.arg, v.Type())
+		}
+		return setCodecInt("cbor", v, -1-int64(h.arg), 0, true)
+	case cborBytes, cborText:
+		switch {
+		case v.Kind() == reflect.String,
+			v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8,
+			v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+		default:
+			return d.typeError(h, v.Type())
+		}
+		b, err := d.readString(h)
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.String:
+			v.SetString(string(b))
+		case reflect.Slice:
+			v.SetBytes(b)
+		default:
+			reflect.Copy(v, reflect.ValueOf(b))
+		}
+	case cborArray:
+		return d.decodeArray(h, v, depth)
+	case cborMap:
+		switch v.Kind() {
+		case reflect.Map:
+			return d.decodeMap(h, v, depth)
+		case reflect.Struct:
+			return d.decodeStruct(h, v, depth)
+		}
+		return d.typeError(h, v.Type())
+	case cborTag:
+		if h.arg == 2 || h.arg == 3 {
+			n, err := d.decodeBigInt(h)
+			if err != nil {
+				return err
+			}
+			if n.IsInt64() {
+				return setCodecInt("cbor", v, n.Int64(), 0, true)
+			}
+			if n.IsUint64() {
+				return setCodecInt("cbor", v, 0, n.Uint64(), false)
+			}
+			return fmt.Errorf("cbor: %s overflows %s", n, v.Type())
+		}
+		// tags without a built-in meaning decode their content
+		return d.decode(v, depth+1)
+	case cborSimple:
+		if f, ok := h.float(); ok {
+			switch v.Kind() {
+			case reflect.Float32, reflect.Float64:
+				v.SetFloat(f)
+				return nil
+			}
+		} else if (h.info == 20 || h.info == 21) && v.Kind() == reflect.Bool {
+			v.SetBool(h.info == 21)
+			return nil
+		}
+		return d.typeError(h, v.Type())
+	}
+	return nil
+}
+
+// decodeTime decodes a standard date/time string (tag 0), an epoch-based
+// date/time (tag 1) or an untagged string or number into a UTC time.
+func (d *cborDecoder) decodeTime(h cborHead, depth int) (time.Time, error) {
+	if h.major == cborTag {
+		if h.arg != 0 && h.arg != 1 {
+			return time.Time{}, fmt.Errorf("cbor: cannot decode tag %d into time.Time", h.arg)
+		}
+		if depth+1 > cborMaxDepth {
+			return time.Time{}, errCBORDepth
+		}
+		tag := h.arg
+		var err error
+		if h, err = d.readHead(); err != nil {
+			return time.Time{}, err
+		}
+		if tag == 0 && h.major != cborText || tag == 1 && (h.major == cborText || h.major == cborTag) {
+			return time.Time{}, fmt.Errorf("cbor: invalid content for time tag %d", tag)
+		}
+	}
+	switch h.major {
+	case cborText:
+		b, err := d.readString(h)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t, err := time.Parse(time.RFC3339Nano, string(b))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cbor: %w", err)
+		}
+		return t.UTC(), nil
+	case cborUint, cborNegInt:
+		// keep the seconds well inside the range time.Time can represent
+		if h.arg > 1<<62 {
+			return time.Time{}, fmt.Errorf("cbor: epoch time %d is out of range", h.arg)
+		}
+		sec := int64(h.arg)
+		if h.major == cborNegInt {
+			sec = -1 - sec
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	case cborSimple:
+		f, ok := h.float()
+		if !ok {
+			break
+		}
+		if math.IsNaN(f) || math.Abs(f) > 1<<62 {
+			return time.Time{}, fmt.Errorf("cbor: epoch time %v is out of range", f)
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("cbor: cannot decode %s into time.Time", cborMajorNames[h.major])
+}
+
+// decodeBigInt decodes an integer or a bignum (tags 2 and 3).
+func (d *cborDecoder) decodeBigInt(h cborHead) (*big.Int, error) {
+	switch {
+	case h.major == cborUint:
+		return new(big.Int).SetUint64(h.arg), nil
+	case h.major == cborNegInt:
+		n := new(big.Int).SetUint64(h.arg)
+		return n.Not(n), nil
+	case h.major != cborTag || h.arg != 2 && h.arg != 3:
+		return nil, fmt.Errorf("cbor: cannot decode %s into big.Int", cborMajorNames[h.major])
+	}
+	tag := h.arg
+	ch, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	if ch.major != cborBytes {
+		return nil, fmt.Errorf("cbor: invalid content for bignum tag %d", tag)
+	}
+	b, err := d.readString(ch)
+	if err != nil {
+		return nil, err
+	}
+	n := new(big.Int).SetBytes(b)
+	if tag == 3 {
+		n.Not(n)
+	}
+	return n, nil
+}
+
+func (d *cborDecoder) decodeArray(h cborHead, v reflect.Value, depth int) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		// grow as elements arrive, a forged length must not allocate up front
+		capacity := 0
+		if !h.indefinite() {
+			capacity = int(min(h.arg, 1024))
+		}
+		slice := reflect.MakeSlice(v.Type(), 0, capacity)
+		elem := reflect.New(v.Type().Elem()).Elem()
+		err := d.each(h, func(eh cborHead) error {
+			elem.Set(reflect.Zero(elem.Type()))
+			if err := d.decodeValue(eh, elem, depth+1); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		v.Set(slice)
+	case reflect.Array:
+		i := 0
+		err := d.each(h, func(eh cborHead) error {
+			defer func() { i++ }()
+			if i < v.Len() {
+				return d.decodeValue(eh, v.Index(i), depth+1)
+			}
+			return d.skip(eh, depth+1)
+		})
+		if err != nil {
+			return err
+		}
+		for ; i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+	default:
+		return d.typeError(h, v.Type())
+	}
+	return nil
+}
+
+func (d *cborDecoder) decodeMap(h cborHead, v reflect.Value, depth int) error {
+	t := v.Type()
+	if v.IsNil() {
+		capacity := 0
+		if !h.indefinite() {
+			capacity = int(min(h.arg, 1024))
+		}
+		v.Set(reflect.MakeMapWithSize(t, capacity))
+	}
+	return d.each(h, func(kh cborHead) error {
+		key := reflect.New(t.Key()).Elem()
+		if err := d.decodeValue(kh, key, depth+1); err != nil {
+			return err
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if err := d.decode(elem, depth+1); err != nil {
+			return err
+		}
+		if !key.Comparable() {
+			return fmt.Errorf("cbor: unhashable map key of type %s", key.Type())
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	})
+}
+
+func (d *cborDecoder) decodeStruct(h cborHead, v reflect.Value, depth int) error {
+	fields := codecFields(v.Type(), "cbor")
+	return d.each(h, func(kh cborHead) error {
+		if kh.major != cborText {
+			// only text keys name fields
+			if err := d.skip(kh, depth+1); err != nil {
+				return err
+			}
+			return d.skipNext(depth + 1)
+		}
+		b, err := d.readString(kh)
+		if err != nil {
+			return err
+		}
+		field := lookupCodecField(fields, string(b))
+		fv, ok := reflect.Value{}, false
+		if field != nil {
+			fv, ok = settableFieldByIndex(v, field.index)
+		}
+		if !ok {
+			return d.skipNext(depth + 1)
+		}
+		return d.decode(fv, depth+1)
+	})
+}
+
+func (d *cborDecoder) decodeNextAny(depth int) (any, error) {
+	if depth > cborMaxDepth {
+		return nil, errCBORDepth
+	}
+	h, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeAny(h, depth)
+}
+
+func (d *cborDecoder) decodeAny(h cborHead, depth int) (any, error) {
+	switch h.major {
+	case cborUint:
+		if h.arg <= math.MaxInt64 {
+			return int64(h.arg), nil
+		}
+		return h.arg, nil
+	case cborNegInt:
+		if h.arg <= math.MaxInt64 {
+			return -1 - int64(h.arg), nil
+		}
+		return d.decodeBigInt(h)
+	case cborBytes:
+		return d.readString(h)
+	case cborText:
+		b, err := d.readString(h)
+		return string(b), err
+	case cborArray:
+		var a []any
+		err := d.decodeArray(h, reflect.ValueOf(&a).Elem(), depth)
+		return a, err
+	case cborMap:
+		return d.decodeAnyMap(h, depth)
+	case cborTag:
+		switch h.arg {
+		case 0, 1:
+			return d.decodeTime(h, depth)
+		case 2, 3:
+			return d.decodeBigInt(h)
+		}
+		x, err := d.decodeNextAny(depth + 1)
+		return CBORTag{Number: h.arg, Content: x}, err
+	}
+	switch h.info {
+	case 20, 21:
+		return h.info == 21, nil
+	case 22, 23:
+		return nil, nil
+	case cborIndefinite:
+		return nil, errors.New("cbor: unexpected break")
+	}
+	if f, ok := h.float(); ok {
+		return f, nil
+	}
+	return CBORSimple(h.arg), nil
+}
+
+// decodeAnyMap decodes a map into map[string]any, or into map[any]any when a
+// key is not a string.
+func (d *cborDecoder) decodeAnyMap(h cborHead, depth int) (any, error) {
+	m := make(map[string]any)
+	var generic map[any]any
+	err := d.each(h, func(kh cborHead) error {
+		key, err := d.decodeAny(kh, depth+1)
+		if err != nil {
+			return err
+		}
+		val, err := d.decodeNextAny(depth + 1)
+		if err != nil {
+			return err
+		}
+		if s, ok := key.(string); ok && generic == nil {
+			m[s] = val
+			return nil
+		}
+		if generic == nil {
+			generic = make(map[any]any, len(m)+1)
+			for k, v := range m {
+				generic[k] = v
+			}
+		}
+		if key != nil && !reflect.ValueOf(key).Comparable() {
+			return fmt.Errorf("cbor: unhashable map key of type %T", key)
+		}
+		generic[key] = val
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if generic != nil {
+		return generic, nil
+	}
+	return m, nil
+}
+
+// skipNext reads and discards the next data item.
+func (d *cborDecoder) skipNext(depth int) error {
+	if depth > cborMaxDepth {
+		return errCBORDepth
+	}
+	h, err := d.readHead()
+	if err != nil {
+		return err
+	}
+	return d.skip(h, depth)
+}
+
+// skip discards the data item whose head is h.
+func (d *cborDecoder) skip(h cborHead, depth int) error {
+	if depth > cborMaxDepth {
+		return errCBORDepth
+	}
+	switch h.major {
+	case cborBytes, cborText:
+		if h.indefinite() {
+			_, err := d.readString(h)
+			return err
+		}
+		n, err := h.length()
+		if err != nil {
+			return err
+		}
+		if _, err = io.CopyN(io.Discard, d.r, int64(n)); err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	case cborArray:
+		return d.each(h, func(eh cborHead) error { return d.skip(eh, depth+1) })
+	case cborMap:
+		return d.each(h, func(kh cborHead) error {
+			if err := d.skip(kh, depth+1); err != nil {
+				return err
+			}
+			return d.skipNext(depth + 1)
+		})
+	case cborTag:
+		return d.skipNext(depth + 1)
+	case cborSimple:
+		if h.isBreak() {
+			return errors.New("cbor: unexpected break")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package slim
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func cborEncode(t testing.TB, c CBORCodec, v any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, v, ""); err != nil {
+		t.Fatalf("encode %#v: %v", v, err)
+	}
+	return buf.Bytes()
+}
+
+func cborDecodeHex(h string, v any) error {
+	b, _ := hex.DecodeString(h)
+	return (CBORCodec{}).Decode(bytes.NewReader(b), v)
+}
+
+func TestCBOR_EncodeFormats(t *testing.T) {
+	huge, _ := new(big.Int).SetString("18446744073709551616", 10)
+	cases := []struct {
+		v    any
+		want string
+	}{
+		// examples from RFC 8949 appendix A
+		{0, "00"},
+		{23, "17"},
+		{24, "1818"},
+		{1000, "1903e8"},
+		{1000000, "1a000f4240"},
+		{uint64(math.MaxUint64), "1bffffffffffffffff"},
+		{-1, "20"},
+		{-1000, "3903e7"},
+		{int64(math.MinInt64), "3b7fffffffffffffff"},
+		{huge, "c249010000000000000000"},
+		{new(big.Int).Neg(new(big.Int).Add(huge, big.NewInt(1))), "c349010000000000000000"},
+		{big.NewInt(-10), "29"},
+		{nil, "f6"},
+		{true, "f5"},
+		{false, "f4"},
+		{CBORSimple(16), "f0"},
+		{CBORSimple(255), "f8ff"},
+		{1.5, "fb3ff8000000000000"},
+		{float32(100000), "fa47c35000"},
+		{"", "60"},
+		{"IETF", "6449455446"},
+		{"\u00fc", "62c3bc"},
+		{[]byte{1, 2, 3, 4}, "4401020304"},
+		{[]int{}, "80"},
+		{[]any{1, []int{2, 3}}, "8201820203"},
+		{map[string]int{"b": 2, "a": 1}, "a2616101616202"},
+		{CBORTag{Number: 32, Content: "http://www.example.com"}, "d82076687474703a2f2f7777772e6578616d706c652e636f6d"},
+		{time.Unix(1363896240, 0), "c11a514b67b0"},
+		{time.Date(2013, 3, 21, 20, 4, 0, 500000000, time.UTC), "c076323031332d30332d32315432303a30343a30302e355a"},
+	}
+	for _, c := range cases {
+		if got := hex.EncodeToString(cborEncode(t, CBORCodec{}, c.v)); got != c.want {
+			t.Errorf("encode %#v = %s, want %s", c.v, got, c.want)
+		}
+	}
+}
+
+func TestCBOR_Deterministic(t *testing.T) {
+	det := CBORCodec{Deterministic: true}
+	cases := []struct {
+		v    any
+		want string
+	}{
+		{0.0, "f90000"},
+		{math.Copysign(0, -1), "f98000"},
+		{1.0, "f93c00"},
+		{1.5, "f93e00"},
+		{65504.0, "f97bff"},
+		{5.960464477539063e-8, "f90001"},
+		{0.00006103515625, "f90400"},
+		{-4.0, "f9c400"},
+		{100000.0, "fa47c35000"},
+		{3.4028234663852886e+38, "fa7f7fffff"},
+		{1.1, "fb3ff199999999999a"},
+		{math.Inf(1), "f97c00"},
+		{math.Inf(-1), "f9fc00"},
+		{math.NaN(), "f97e00"},
+		{float32(1.5), "f93e00"},
+		// keys are sorted by their encoded bytes: shorter first, then bytewise
+		{map[any]int{"aa": 3, "b": 2, 10: 1, -1: 4, false: 5}, "a50a012004616202626161 03f405"},
+		{struct {
+			Long  int `cbor:"long"`
+			Short int `cbor:"z"`
+		}{1, 2}, "a2617a02646c6f6e6701"},
+	}
+	for _, c := range cases {
+		want := strings.ReplaceAll(c.want, " ", "")
+		if got := hex.EncodeToString(cborEncode(t, det, c.v)); got != want {
+			t.Errorf("encode %#v = %s, want %s", c.v, got, want)
+		}
+	}
+}
+
+type cborOuter struct {
+	msgpackInner
+	Name   string            `cbor:"name"`
+	Skip   string            `cbor:"-"`
+	Empty  string            `json:"empty,omitempty"`
+	Age    uint8             `json:"age"`
+	Score  float64           `cbor:"score"`
+	Tags   []string          `cbor:"tags"`
+	Attrs  map[string]any    `cbor:"attrs"`
+	Born   time.Time         `cbor:"born"`
+	Ptr    *int              `cbor:"ptr"`
+	Raw    []byte            `cbor:"raw"`
+	Big    *big.Int          `cbor:"big"`
+	Tag    CBORTag           `cbor:"tag"`
+	Nested map[string][]int8 `cbor:"nested"`
+}
+
+func TestCBOR_RoundTripStruct(t *testing.T) {
+	n := 42
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	in := cborOuter{
+		msgpackInner: msgpackInner{City: "x"},
+		Name:         "bob",
+		Skip:         "skipped",
+		Age:          30,
+		Score:        1.25,
+		Tags:         []string{"a", "b"},
+		Attrs:        map[string]any{"k": "v"},
+		Born:         time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Ptr:          &n,
+		Raw:          []byte{0, 1},
+		Big:          huge,
+		Tag:          CBORTag{Number: 100, Content: "x"},
+		Nested:       map[string][]int8{"n": {-1, 2}},
+	}
+	for _, c := range []CBORCodec{{}, {Deterministic: true}} {
+		b := cborEncode(t, c, in)
+		var out cborOuter
+		if err := c.Decode(bytes.NewReader(b), &out); err != nil {
+			t.Fatal(err)
+		}
+		want := in
+		want.Skip = ""
+		if !reflect.DeepEqual(want, out) {
+			t.Fatalf("round trip mismatch:\n in=%+v\nout=%+v", want, out)
+		}
+
+		var generic map[string]any
+		if err := c.Decode(bytes.NewReader(b), &generic); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := generic["empty"]; ok {
+			t.Fatal("omitempty field was encoded")
+		}
+		if generic["city"] != "x" || generic["age"] != int64(30) || generic["ptr"] != int64(42) {
+			t.Fatalf("generic=%v", generic)
+		}
+		if !generic["born"].(time.Time).Equal(in.Born) || generic["big"].(*big.Int).Cmp(huge) != 0 {
+			t.Fatalf("born=%v big=%v", generic["born"], generic["big"])
+		}
+	}
+}
+
+func TestCBOR_DecodeAny(t *testing.T) {
+	cases := []struct {
+		in   string
+		want any
+	}{
+		{"1bffffffffffffffff", uint64(math.MaxUint64)},
+		{"3bffffffffffffffff", new(big.Int).Not(new(big.Int).SetUint64(math.MaxUint64))},
+		{"f93c00", 1.0},
+		{"f97c00", math.Inf(1)},
+		{"fa47c35000", 100000.0},
+		{"f7", nil},
+		{"f0", CBORSimple(16)},
+		{"c11a514b67b0", time.Unix(1363896240, 0).UTC()},
+		{"c1fb41d452d9ec200000", time.Unix(1363896240, 500000000).UTC()},
+		{"c074323031332d30332d32315432303a30343a30305a", time.Unix(1363896240, 0).UTC()},
+		{"d74401020304", CBORTag{Number: 23, Content: []byte{1, 2, 3, 4}}},
+		// indefinite lengths
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9f018202039f0405ffff", []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+		{"bf61610161629f0203ffff", map[string]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+		{"a201020304", map[any]any{int64(1): int64(2), int64(3): int64(4)}},
+	}
+	for _, c := range cases {
+		var v any
+		if err := cborDecodeHex(c.in, &v); err != nil {
+			t.Errorf("decode %s: %v", c.in, err)
+			continue
+		}
+		if want, ok := c.want.(*big.Int); ok {
+			if got, ok := v.(*big.Int); !ok || got.Cmp(want) != 0 {
+				t.Errorf("decode %s = %#v, want %v", c.in, v, want)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(v, c.want) {
+			t.Errorf("decode %s = %#v, want %#v", c.in, v, c.want)
+		}
+	}
+}
+
+func TestCBOR_DecodeErrors(t *testing.T) {
+	var i8 int8
+	if err := cborDecodeHex("1880", &i8); err == nil {
+		t.Error("expected overflow error")
+	}
+	var u uint
+	if err := cborDecodeHex("20", &u); err == nil {
+		t.Error("expected negative into uint error")
+	}
+	var i64 int64
+	if err := cborDecodeHex("c249010000000000000000", &i64); err == nil {
+		t.Error("expected bignum overflow error")
+	}
+	var s string
+	for _, in := range []string{
+		"f5",         // type mismatch
+		"1c",         // reserved additional information
+		"ff",         // unexpected break
+		"f813",       // simple value in the two byte form
+		"1f",         // indefinite length integer
+		"5f01ff",     // invalid chunk
+		"65616263",   // truncated input
+		"7affffff00", // forged length
+	} {
+		if err := cborDecodeHex(in, &s); err == nil {
+			t.Errorf("decode %s: expected error", in)
+		}
+	}
+	var a []int
+	if err := cborDecodeHex("9affffffff01", &a); err == nil {
+		t.Error("expected truncated array error")
+	}
+	var tm time.Time
+	if err := cborDecodeHex("c1f97e00", &tm); err == nil {
+		t.Error("expected invalid epoch time error")
+	}
+	if err := cborDecodeHex("c001", &tm); err == nil {
+		t.Error("expected invalid time content error")
+	}
+	if err := cborDecodeHex(strings.Repeat("81", cborMaxDepth+1)+"f6", new(any)); err == nil {
+		t.Error("expected depth error")
+	}
+	if err := (CBORCodec{}).Decode(bytes.NewReader(nil), s); err == nil {
+		t.Error("expected non-pointer error")
+	}
+}
+
+func TestCBOR_SkipsUnknownFields(t *testing.T) {
+	b := cborEncode(t, CBORCodec{}, map[any]any{
+		"name":    "bob",
+		"unknown": map[string]any{"a": []any{1, "x", CBORTag{Number: 9, Content: []byte{1}}}},
+		1:         2,
+		"AGE":     7,
+	})
+	var out cborOuter
+	if err := (CBORCodec{}).Decode(bytes.NewReader(b), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "bob" || out.Age != 7 {
+		t.Fatalf("out=%+v", out)
+	}
+}
+
+func TestCBOR_BindAndRespond(t *testing.T) {
+	s := New()
+	s.POST("/", func(c Context) error {
+		var v map[string]any
+		if err := c.Bind(&v); err != nil {
+			return err
+		}
+		return c.Respond(http.StatusOK, v)
+	})
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(cborEncode(t, CBORCodec{}, map[string]any{"a": 1})))
+	r.Header.Set(HeaderContentType, MIMEApplicationCBOR)
+	r.Header.Set(HeaderAccept, MIMEApplicationCBOR)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get(HeaderContentType) != MIMEApplicationCBOR {
+		t.Fatalf("code=%d type=%q", w.Code, w.Header().Get(HeaderContentType))
+	}
+	if got := hex.EncodeToString(w.Body.Bytes()); got != "a1616101" {
+		t.Fatalf("body=%s", got)
+	}
+}
+
+// FuzzCBORRoundTrip checks that any input the decoder accepts re-encodes to
+// a stable deterministic form.
+func FuzzCBORRoundTrip(f *testing.F) {
+	for _, seed := range []string{
+		"00", "3bffffffffffffffff", "c249010000000000000000", "f97e00", "fb3ff199999999999a",
+		"c1fb41d452d9ec200000", "c076323031332d30332d32315432303a30343a30302e355a",
+		"9f018202039f0405ffff", "bf61610161629f0203ffff", "a3f4f5f6f7f0f9", "d82076687474703a2f2f",
+		"5f42010243030405ff", "a26161016162820203",
+	} {
+		b, _ := hex.DecodeString(seed)
+		f.Add(b)
+	}
+	det := CBORCodec{Deterministic: true}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var v any
+		if err := det.Decode(bytes.NewReader(data), &v); err != nil {
+			return
+		}
+		b1 := cborEncode(t, det, v)
+		var v2 any
+		if err := det.Decode(bytes.NewReader(b1), &v2); err != nil {
+			t.Fatalf("decode %x: %v", b1, err)
+		}
+		if b2 := cborEncode(t, det, v2); !bytes.Equal(b1, b2) {
+			t.Fatalf("unstable encoding of %x:\n%x\n%x", data, b1, b2)
+		}
+		// decoding into a typed value must not panic
+		var out cborOuter
+		_ = det.Decode(bytes.NewReader(data), &out)
+	})
+}
@@ -3,7 +3,14 @@ package slim
 import (
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 // Codec 定义了数据的编码和解码接口
@@ -44,3 +51,270 @@ func (XMLCodec) Encode(w io.Writer, v any, indent string) error {
 func (XMLCodec) Decode(r io.Reader, v any) error {
 	return xml.NewDecoder(r).Decode(v)
 }
+
+// Codecs 是按媒体类型注册的编解码器表，用于请求体绑定和 `Context.Respond`。
+//
+// 查找时先匹配完整的媒体类型，再按结构化语法后缀匹配，例如
+// `application/problem+json` 使用 `application/json` 的编解码器。
+type Codecs struct {
+	mu     sync.RWMutex
+	codecs map[string]Codec
+	types  []string // 按注册顺序排列的媒体类型，协商时靠前的优先
+}
+
+// NewCodecs 返回空的编解码器表
+func NewCodecs() *Codecs {
+	return &Codecs{codecs: make(map[string]Codec)}
+}
+
+// Register 为媒体类型注册编解码器，已注册的媒体类型会被替换，
+// 参数（如 `charset`）会被忽略。
+func (cs *Codecs) Register(mediaType string, codec Codec) {
+	mt := normalizeMediaType(mediaType)
+	if mt == "" || codec == nil {
+		panic("slim: invalid codec registration for " + strconv.Quote(mediaType))
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if _, ok := cs.codecs[mt]; !ok {
+		cs.types = append(cs.types, mt)
+	}
+	cs.codecs[mt] = codec
+}
+
+// Lookup 返回媒体类型对应的编解码器以及注册时使用的媒体类型
+func (cs *Codecs) Lookup(mediaType string) (Codec, string, bool) {
+	if cs == nil {
+		return nil, "", false
+	}
+	mt := normalizeMediaType(mediaType)
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if codec, ok := cs.codecs[mt]; ok {
+		return codec, mt, true
+	}
+	// 结构化语法后缀，见 RFC 6839
+	if i := strings.LastIndexByte(mt, '+'); i > 0 {
+		suffix := "application/" + mt[i+1:]
+		if codec, ok := cs.codecs[suffix]; ok {
+			return codec, suffix, true
+		}
+	}
+	return nil, "", false
+}
+
+// MediaTypes 按注册顺序返回所有已注册的媒体类型
+func (cs *Codecs) MediaTypes() []string {
+	if cs == nil {
+		return nil
+	}
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return slices.Clone(cs.types)
+}
+
+func normalizeMediaType(mediaType string) string {
+	mt, _, _ := strings.Cut(mediaType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// codecField 是编解码时使用的结构体字段，index 是经由嵌入结构体到达该字段的路径。
+type codecField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+type codecFieldsKey struct {
+	typ reflect.Type
+	tag string
+}
+
+var codecFieldCache sync.Map // map[codecFieldsKey][]codecField
+
+// codecFields 返回结构体编解码时使用的字段。字段名依次取自 tag 标签、`json` 标签
+// 和字段名，标签支持 "-" 和 "omitempty" 选项，嵌入结构体的导出字段会被提升，
+// 名称冲突时层级最浅的字段优先，与 encoding/json 一致。
+func codecFields(t reflect.Type, tag string) []codecField {
+	key := codecFieldsKey{t, tag}
+	if fields, ok := codecFieldCache.Load(key); ok {
+		return fields.([]codecField)
+	}
+	type candidate struct {
+		codecField
+		depth int
+	}
+	var candidates []candidate
+	var collect func(t reflect.Type, index []int, depth int)
+	collect = func(t reflect.Type, index []int, depth int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			value, ok := sf.Tag.Lookup(tag)
+			if !ok {
+				value = sf.Tag.Get("json")
+			}
+			if value == "-" {
+				continue
+			}
+			name, options, _ := strings.Cut(value, ",")
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != timeType && depth < 16 {
+				// 提升嵌入结构体的导出字段
+				collect(ft, append(index[:len(index):len(index)], i), depth+1)
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			candidates = append(candidates, candidate{codecField{
+				name:      name,
+				index:     append(index[:len(index):len(index)], i),
+				omitEmpty: hasTagOption(options, "omitempty"),
+			}, depth})
+		}
+	}
+	collect(t, nil, 0)
+
+	best := make(map[string]int, len(candidates))
+	for i, c := range candidates {
+		if j, ok := best[c.name]; !ok || c.depth < candidates[j].depth {
+			best[c.name] = i
+		}
+	}
+	fields := make([]codecField, 0, len(best))
+	for i, c := range candidates {
+		if best[c.name] == i {
+			fields = append(fields, c.codecField)
+		}
+	}
+	actual, _ := codecFieldCache.LoadOrStore(key, fields)
+	return actual.([]codecField)
+}
+
+// lookupCodecField 按名称查找字段，找不到时忽略大小写再查找一次。
+func lookupCodecField(fields []codecField, name string) *codecField {
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, name) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// fieldByIndex 返回 index 处的字段，途经的嵌入指针为 nil 时 ok 为 false。
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// settableFieldByIndex 返回 index 处的字段，并为途经的 nil 嵌入指针分配内存，
+// 嵌入指针未导出而无法分配时 ok 为 false。
+func settableFieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, v.CanSet()
+}
+
+// isEmptyValue 判断值是否满足 "omitempty" 的省略条件
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// codecReader 是解码二进制格式时使用的读取器
+type codecReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// setCodecInt 将解码得到的整数写入 v，signed 为 false 时 u 为整数的值，
+// name 是用于错误信息的格式名称。
+func setCodecInt(name string, v reflect.Value, i int64, u uint64, signed bool) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !signed {
+			if u > math.MaxInt64 {
+				return fmt.Errorf("%s: %d overflows %s", name, u, v.Type())
+			}
+			i = int64(u)
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("%s: %d overflows %s", name, i, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if signed {
+			if i < 0 {
+				return fmt.Errorf("%s: %d overflows %s", name, i, v.Type())
+			}
+			u = uint64(i)
+		}
+		if v.OverflowUint(u) {
+			return fmt.Errorf("%s: %d overflows %s", name, u, v.Type())
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if signed {
+			v.SetFloat(float64(i))
+		} else {
+			v.SetFloat(float64(u))
+		}
+	default:
+		return fmt.Errorf("%s: cannot decode integer into %s", name, v.Type())
+	}
+	return nil
+}
+
+// slimCodec 转发到 `Slim.JSONCodec` 或 `Slim.XMLCodec`，
+// 使替换这些字段后注册表中的编解码器随之生效。
+type slimCodec func() Codec
+
+func (f slimCodec) Encode(w io.Writer, v any, indent string) error {
+	return f().Encode(w, v, indent)
+}
+
+func (f slimCodec) Decode(r io.Reader, v any) error {
+	return f().Decode(r, v)
+}
@@ -2,6 +2,11 @@ package slim
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -84,3 +89,94 @@ func TestXMLCodec_Decode(t *testing.T) {
 		t.Fatalf("A=%d", v.A)
 	}
 }
+
+// lineCodec encodes values as a single line of text, for registry tests.
+type lineCodec struct{}
+
+func (lineCodec) Encode(w io.Writer, v any, indent string) error {
+	_, err := fmt.Fprintf(w, "%v\n", v)
+	return err
+}
+
+func (lineCodec) Decode(r io.Reader, v any) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	*(v.(*string)) = strings.TrimSpace(string(b))
+	return nil
+}
+
+func TestCodecs_Lookup(t *testing.T) {
+	cs := NewCodecs()
+	cs.Register("application/json; charset=utf-8", JSONCodec{})
+	cs.Register("text/x-line", lineCodec{})
+
+	if _, mt, ok := cs.Lookup("Application/JSON"); !ok || mt != MIMEApplicationJSON {
+		t.Fatalf("json lookup: %q %v", mt, ok)
+	}
+	if _, mt, ok := cs.Lookup("application/problem+json; charset=utf-8"); !ok || mt != MIMEApplicationJSON {
+		t.Fatalf("suffix lookup: %q %v", mt, ok)
+	}
+	if _, _, ok := cs.Lookup("application/vnd.api+xml"); ok {
+		t.Fatal("xml suffix must not match without an xml codec")
+	}
+	if got := cs.MediaTypes(); !slices.Equal(got, []string{MIMEApplicationJSON, "text/x-line"}) {
+		t.Fatalf("media types: %v", got)
+	}
+}
+
+func TestCodecs_BindAndRespond(t *testing.T) {
+	s := New()
+	s.ErrorHandler = func(c Context, err error) {
+		if he, ok := err.(*HTTPError); ok {
+			c.NoContent(he.Code)
+		}
+	}
+	s.Codecs.Register("text/x-line", lineCodec{})
+	s.POST("/echo", func(c Context) error {
+		var v string
+		if ct := c.Request().Header.Get(HeaderContentType); strings.HasPrefix(ct, "text/") {
+			if err := c.Bind(&v); err != nil {
+				return err
+			}
+			return c.Respond(http.StatusOK, v)
+		}
+		var m map[string]any
+		if err := c.Bind(&m); err != nil {
+			return err
+		}
+		return c.Respond(http.StatusOK, m)
+	})
+	do := func(ctype, accept, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(body))
+		r.Header.Set(HeaderContentType, ctype)
+		if accept != "" {
+			r.Header.Set(HeaderAccept, accept)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("text/x-line", "text/x-line", "hello")
+	if w.Code != http.StatusOK || w.Body.String() != "hello\n" || w.Header().Get(HeaderContentType) != "text/x-line" {
+		t.Fatalf("line: %d %q %q", w.Code, w.Body.String(), w.Header().Get(HeaderContentType))
+	}
+	if w.Header().Get(HeaderVary) != HeaderAccept {
+		t.Fatalf("vary: %q", w.Header().Get(HeaderVary))
+	}
+	w = do("application/merge-patch+json", "", `{"a":1}`)
+	if w.Code != http.StatusOK || w.Header().Get(HeaderContentType) != MIMEApplicationJSONCharsetUTF8 || !strings.Contains(w.Body.String(), `"a"`) {
+		t.Fatalf("json: %d %q %q", w.Code, w.Body.String(), w.Header().Get(HeaderContentType))
+	}
+	if w = do("text/x-line", "text/xml", "hi"); w.Header().Get(HeaderContentType) != MIMETextXMLCharsetUTF8 || !strings.Contains(w.Body.String(), "<string>hi</string>") {
+		t.Fatalf("xml: %d %q", w.Code, w.Header().Get(HeaderContentType))
+	}
+	if w = do("application/json", "image/png", `{"a":1}`); w.Code != http.StatusNotAcceptable {
+		t.Fatalf("not acceptable: %d", w.Code)
+	}
+	if w = do("application/x-unknown", "", `x`); w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("unsupported: %d", w.Code)
+	}
+}
@@ -0,0 +1,227 @@
+package slim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// GenerateETag returns an entity tag for the given content. Strong tags are
+// meant for byte-for-byte identical representations, weak tags (`W/"..."`)
+// for semantically equivalent ones.
+func GenerateETag(b []byte, weak bool) string {
+	sum := sha256.Sum256(b)
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// quoteETag makes sure the entity tag is a valid quoted string.
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// condResult is the result of an HTTP request precondition check.
+// See https://tools.ietf.org/html/rfc7232 section 3.
+type condResult int
+
+const (
+	condNone condResult = iota
+	condTrue
+	condFalse
+)
+
+// checkPreconditions evaluates request preconditions in the order defined by
+// RFC 7232 section 6 and returns the status code the request must be answered
+// with, or 0 when the request should be processed normally.
+func checkPreconditions(r *http.Request, etag string, modtime time.Time) int {
+	ch := checkIfMatch(r, etag)
+	if ch == condNone {
+		ch = checkIfUnmodifiedSince(r, modtime)
+	}
+	if ch == condFalse {
+		return http.StatusPreconditionFailed
+	}
+	switch checkIfNoneMatch(r, etag) {
+	case condFalse:
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return http.StatusNotModified
+		}
+		return http.StatusPreconditionFailed
+	case condNone:
+		if checkIfModifiedSince(r, modtime) == condFalse {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+func checkIfMatch(r *http.Request, etag string) condResult {
+	im := r.Header.Get(HeaderIfMatch)
+	if im == "" {
+		return condNone
+	}
+	for {
+		im = textproto.TrimString(im)
+		if len(im) == 0 {
+			break
+		}
+		if im[0] == ',' {
+			im = im[1:]
+			continue
+		}
+		if im[0] == '*' {
+			if etag != "" {
+				return condTrue
+			}
+			return condFalse
+		}
+		tag, remain := scanETag(im)
+		if tag == "" {
+			break
+		}
+		if etagStrongMatch(tag, etag) {
+			return condTrue
+		}
+		im = remain
+	}
+	return condFalse
+}
+
+func checkIfUnmodifiedSince(r *http.Request, modtime time.Time) condResult {
+	ius := r.Header.Get(HeaderIfUnmodifiedSince)
+	if ius == "" || isZeroTime(modtime) {
+		return condNone
+	}
+	t, err := http.ParseTime(ius)
+	if err != nil {
+		return condNone
+	}
+	// The Last-Modified header truncates sub-second precision so
+	// the modtime needs to be truncated too.
+	if ret := modtime.Truncate(time.Second).Compare(t); ret <= 0 {
+		return condTrue
+	}
+	return condFalse
+}
+
+func checkIfNoneMatch(r *http.Request, etag string) condResult {
+	inm := r.Header.Get(HeaderIfNoneMatch)
+	if inm == "" {
+		return condNone
+	}
+	buf := inm
+	for {
+		buf = textproto.TrimString(buf)
+		if len(buf) == 0 {
+			break
+		}
+		if buf[0] == ',' {
+			buf = buf[1:]
+			continue
+		}
+		if buf[0] == '*' {
+			if etag != "" {
+				return condFalse
+			}
+			return condTrue
+		}
+		tag, remain := scanETag(buf)
+		if tag == "" {
+			break
+		}
+		if etagWeakMatch(tag, etag) {
+			return condFalse
+		}
+		buf = remain
+	}
+	return condTrue
+}
+
+func checkIfModifiedSince(r *http.Request, modtime time.Time) condResult {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return condNone
+	}
+	ims := r.Header.Get(HeaderIfModifiedSince)
+	if ims == "" || isZeroTime(modtime) {
+		return condNone
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return condNone
+	}
+	// The Last-Modified header truncates sub-second precision so
+	// the modtime needs to be truncated too.
+	if ret := modtime.Truncate(time.Second).Compare(t); ret <= 0 {
+		return condFalse
+	}
+	return condTrue
+}
+
+// scanETag determines if a syntactically valid ETag is present at s. If so,
+// the ETag and remaining text after consuming ETag is returned. Otherwise,
+// it returns "", "".
+func scanETag(s string) (etag string, remain string) {
+	s = textproto.TrimString(s)
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+	if len(s[start:]) < 2 || s[start] != '"' {
+		return "", ""
+	}
+	// ETag is either W/"text" or "text".
+	// See RFC 7232 2.3.
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		// Character values allowed in ETags.
+		case c == 0x21 || c >= 0x23 && c <= 0x7E || c >= 0x80:
+		case c == '"':
+			return s[:i+1], s[i+1:]
+		default:
+			return "", ""
+		}
+	}
+	return "", ""
+}
+
+// etagStrongMatch reports whether a and b match using strong ETag comparison.
+// Assumes a and b are valid ETags.
+func etagStrongMatch(a, b string) bool {
+	return a == b && a != "" && a[0] == '"'
+}
+
+// etagWeakMatch reports whether a and b match using weak ETag comparison.
+// Assumes a and b are valid ETags.
+func etagWeakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+var unixEpochTime = time.Unix(0, 0)
+
+// isZeroTime reports whether t is obviously unspecified (either zero or Unix()=0).
+func isZeroTime(t time.Time) bool {
+	return t.IsZero() || t.Equal(unixEpochTime)
+}
+
+// writeNotModified answers the request with 304 Not Modified. Representation
+// metadata other than the validators is removed, see RFC 7232 section 4.1.
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	delete(h, HeaderContentType)
+	delete(h, HeaderContentLength)
+	delete(h, HeaderContentEncoding)
+	if h.Get(HeaderETag) != "" {
+		delete(h, HeaderLastModified)
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
@@ -0,0 +1,61 @@
+package slim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGenerateETag(t *testing.T) {
+	strong := GenerateETag([]byte("hello"), false)
+	if strong[0] != '"' || strong != GenerateETag([]byte("hello"), false) {
+		t.Fatalf("unexpected strong etag %q", strong)
+	}
+	if weak := GenerateETag([]byte("hello"), true); weak != "W/"+strong {
+		t.Fatalf("unexpected weak etag %q", weak)
+	}
+}
+
+func TestContext_CheckPreconditions(t *testing.T) {
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		name   string
+		method string
+		header map[string]string
+		done   bool
+		status int
+		err    error
+	}{
+		{"no conditions", http.MethodGet, nil, false, 0, nil},
+		{"if-none-match hit", http.MethodGet, map[string]string{HeaderIfNoneMatch: `"v1"`}, true, http.StatusNotModified, nil},
+		{"if-none-match weak hit", http.MethodHead, map[string]string{HeaderIfNoneMatch: `W/"v0", W/"v1"`}, true, http.StatusNotModified, nil},
+		{"if-none-match miss", http.MethodGet, map[string]string{HeaderIfNoneMatch: `"v0"`}, false, 0, nil},
+		{"if-none-match on write", http.MethodPut, map[string]string{HeaderIfNoneMatch: `*`}, true, 0, ErrPreconditionFailed},
+		{"if-modified-since", http.MethodGet, map[string]string{HeaderIfModifiedSince: modtime.Format(http.TimeFormat)}, true, http.StatusNotModified, nil},
+		{"if-match hit", http.MethodPut, map[string]string{HeaderIfMatch: `"v1"`}, false, 0, nil},
+		{"if-match miss", http.MethodPut, map[string]string{HeaderIfMatch: `"v0"`}, true, 0, ErrPreconditionFailed},
+		{"if-unmodified-since", http.MethodPatch, map[string]string{HeaderIfUnmodifiedSince: modtime.Add(-time.Hour).Format(http.TimeFormat)}, true, 0, ErrPreconditionFailed},
+	}
+	s := New()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/", nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			c := s.NewContext(w, r)
+			done, err := c.CheckPreconditions("v1", modtime)
+			if done != tc.done || err != tc.err {
+				t.Fatalf("done=%v err=%v", done, err)
+			}
+			if c.Response().Status() != tc.status {
+				t.Fatalf("status=%d", c.Response().Status())
+			}
+			if got := w.Header().Get(HeaderETag); got != `"v1"` {
+				t.Fatalf("etag=%q", got)
+			}
+		})
+	}
+}
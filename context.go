@@ -14,6 +14,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -52,6 +53,7 @@ type Context interface {
 	Is(types ...string) string
 	// Accepts 返回支持的权重最高的媒体类型，若匹配失败则会返回空字符串。
 	// 给出的值可以是标准的媒体类型（如 application/json），也可以是扩展名（如 json、xml 等）。
+	// 以下 Accepts* 方法都会将对应的请求报头添加到响应报头 Vary 中。
 	Accepts(expect ...string) string
 	// AcceptsEncodings 返回支持的权重最高的编码方式，若匹配失败则会返回空字符串。
 	AcceptsEncodings(encodings ...string) string
@@ -94,8 +96,17 @@ type Context interface {
 	FormFile(name string) (*multipart.FileHeader, error)
 	Header(key string) string
 	SetHeader(key string, values ...string)
+	// Vary adds request header names to the `Vary` response header, ignoring
+	// names that are already listed.
+	Vary(headers ...string)
+	// CacheControl returns a builder for the `Cache-Control` response header,
+	// starting from the directives already set on the response.
+	CacheControl() *CacheControl
 	// MultipartForm returns the multipart form.
 	MultipartForm() (*multipart.Form, error)
+	// Upload returns an iterator over the parts of a multipart request which
+	// streams file parts instead of spooling them to disk.
+	Upload(config UploadConfig) (*Upload, error)
 	// Cookie returns the named cookie provided in the request.
 	Cookie(name string) (*http.Cookie, error)
 	// SetCookie adds a `Set-Cookie` header in HTTP response.
@@ -110,10 +121,20 @@ type Context interface {
 	// does it based on Content-Type header.
 	Bind(i any) error
 	// Validate validates provided `i`. It is usually called after `Context#Bind()`.
-	// Validator must be registered using `Slim#Validator`.
+	// Validator must be registered using `Slim#Validator`, `ValidationErrors`
+	// are returned as a 422 `HTTPError`.
 	Validate(i any) error
 	// Written returns whether the context response has been written to
 	Written() bool
+	// CheckPreconditions sets the `ETag` and `Last-Modified` validators and
+	// evaluates the conditional request headers against them. When done is
+	// true the request has been answered: either a 304 Not Modified response
+	// has been written, or err is `ErrPreconditionFailed` and should be returned.
+	//
+	//	if done, err := c.CheckPreconditions(etag, updatedAt); done {
+	//		return err
+	//	}
+	CheckPreconditions(etag string, lastModified time.Time) (done bool, err error)
 	// Render renders a template with data and sends a text/html response with status
 	// code. Renderer must be registered using `Slim.Renderer`.
 	Render(code int, name string, data any) error
@@ -143,8 +164,37 @@ type Context interface {
 	XMLBlob(code int, b []byte) error
 	// Blob sends a blob response with a status code and content type.
 	Blob(code int, contentType string, b []byte) error
+	// Respond sends i encoded with the codec of `Slim.Codecs` whose media type
+	// best matches the `Accept` header, the first registered one when the
+	// header is missing. It returns `ErrNotAcceptable` if no codec matches.
+	Respond(code int, i any) error
+	// Negotiate sends data in the representation among offers that best
+	// matches the `Accept` header, the first offer when the header is missing.
+	// An offer is "json", "xml", "jsonp", "html", "text" or the media type (or
+	// short name, e.g. "msgpack") of a codec in `Slim.Codecs`; "html:<name>"
+	// renders the named template with `Slim.Renderer`. The "jsonp" offer only
+	// applies when a query parameter listed in `Slim.JSONPCallbacks` is set,
+	// the callback being checked only when JSONP is chosen. Offers without a
+	// registered codec are ignored and only the first "html" offer counts.
+	// Without offers the media types of `Slim.Codecs` are offered. It returns
+	// `ErrNotAcceptable` if no offer matches.
+	Negotiate(code int, data any, offers ...string) error
+	// Locale returns the locale of the request set by `SetLocale`, or an
+	// empty string when none was set.
+	Locale() string
+	// SetLocale sets the locale used by `T`.
+	SetLocale(locale string)
+	// T translates key in the request locale with `Slim.Translator`, args
+	// format the message. It returns the key itself when no translator is
+	// registered.
+	T(key string, args ...any) string
 	// Stream sends a streaming response with status code and content type.
 	Stream(code int, contentType string, r io.Reader) error
+	// StreamRange sends the content of r, which holds size bytes, honouring
+	// `Range` requests (including multipart/byteranges), `If-Range` and the
+	// other conditional request headers. If contentType is empty it is derived
+	// from the extension of name, modtime is used for `Last-Modified`.
+	StreamRange(contentType, name string, modtime time.Time, r io.ReaderAt, size int64) error
 	// File sends a response with the content of the file.
 	File(file string, filesystem ...fs.FS) error
 	// Attachment sends a response as attachment, prompting client to save the
@@ -201,7 +251,7 @@ type contextImpl struct {
 	currentParams PathParams
 	negotiator    *Negotiator
 	query         url.Values
-	store         map[string]any
+	store         map[any]any // keyed by string or typed `Key`, cleared on Reset
 	slim          *Slim
 	mu            sync.RWMutex
 }
@@ -219,9 +269,6 @@ func (x *contextImpl) Err() error {
 }
 
 func (x *contextImpl) Value(key any) any {
-	x.mu.RLock()
-	defer x.mu.RUnlock()
-
 	if k, ok := key.(*contextKey); ok {
 		switch k.name {
 		case SlimContextKey.name:
@@ -235,13 +282,15 @@ func (x *contextImpl) Value(key any) any {
 		}
 	}
 
-	if ks, ok := key.(string); ok {
-		value, has := x.store[ks]
-		if has {
+	switch key.(type) {
+	case string, storeKey:
+		if value, has := x.value(key); has {
 			return value
 		}
 	}
 
+	// the lock is not held here, the request context looks up the store
+	// again for typed keys
 	return x.request.Context().Value(key)
 }
 
@@ -259,7 +308,9 @@ func (x *contextImpl) Reset(w http.ResponseWriter, r *http.Request) {
 	*x.pathParams = (*x.pathParams)[:0]
 	x.currentParams = nil
 	x.query = nil
-	x.store = nil
+	x.mu.Lock()
+	clear(x.store)
+	x.mu.Unlock()
 }
 
 // Request returns `*http.Request`.
@@ -276,9 +327,26 @@ func (x *contextImpl) wrap(r *http.Request) *http.Request {
 	ctx := r.Context()
 	ctx = context.WithValue(ctx, SlimContextKey, x.slim)
 	ctx = context.WithValue(ctx, ContextKey, x)
+	ctx = storeContext{ctx, x}
 	return r.WithContext(ctx)
 }
 
+// storeContext makes the values stored through typed keys visible to the
+// code that only has the request context.
+type storeContext struct {
+	context.Context
+	x *contextImpl
+}
+
+func (s storeContext) Value(key any) any {
+	if k, ok := key.(storeKey); ok {
+		if value, has := s.x.value(k); has {
+			return value
+		}
+	}
+	return s.Context.Value(key)
+}
+
 // Response returns `slim.ResponseWriter`.
 func (x *contextImpl) Response() ResponseWriter {
 	return x.response
@@ -368,18 +436,22 @@ func (x *contextImpl) Is(types ...string) string {
 }
 
 func (x *contextImpl) Accepts(expect ...string) string {
+	x.Vary(HeaderAccept)
 	return x.slim.negotiator.Type(x.request, expect...)
 }
 
 func (x *contextImpl) AcceptsEncodings(encodings ...string) string {
+	x.Vary(HeaderAcceptEncoding)
 	return x.slim.negotiator.Encoding(x.request, encodings...)
 }
 
 func (x *contextImpl) AcceptsCharsets(charsets ...string) string {
+	x.Vary(HeaderAcceptCharset)
 	return x.slim.negotiator.Charset(x.request, charsets...)
 }
 
 func (x *contextImpl) AcceptsLanguages(languages ...string) string {
+	x.Vary(HeaderAcceptLanguage)
 	return x.slim.negotiator.Language(x.request, languages...)
 }
 
@@ -499,6 +571,13 @@ func (x *contextImpl) MultipartForm() (*multipart.Form, error) {
 	return x.request.MultipartForm, err
 }
 
+func (x *contextImpl) Upload(config UploadConfig) (*Upload, error) {
+	if config.MaxFileSize == 0 {
+		config.MaxFileSize = x.slim.MultipartMemoryLimit
+	}
+	return NewUpload(x.request, config)
+}
+
 func (x *contextImpl) Header(key string) string {
 	return x.request.Header.Get(key)
 }
@@ -514,6 +593,14 @@ func (x *contextImpl) SetHeader(key string, values ...string) {
 	}
 }
 
+func (x *contextImpl) Vary(headers ...string) {
+	AppendVary(x.response.Header(), headers...)
+}
+
+func (x *contextImpl) CacheControl() *CacheControl {
+	return NewCacheControl(x.response.Header())
+}
+
 func (x *contextImpl) Cookie(name string) (*http.Cookie, error) {
 	return x.request.Cookie(name)
 }
@@ -533,10 +620,23 @@ func (x *contextImpl) Get(key string) any {
 }
 
 func (x *contextImpl) Set(key string, val any) {
+	x.setValue(key, val)
+}
+
+// value looks up a request-scoped value stored under any comparable key.
+func (x *contextImpl) value(key any) (any, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	val, ok := x.store[key]
+	return val, ok
+}
+
+// setValue stores a request-scoped value under any comparable key.
+func (x *contextImpl) setValue(key, val any) {
 	x.mu.Lock()
 	defer x.mu.Unlock()
 	if x.store == nil {
-		x.store = make(map[string]any)
+		x.store = make(map[any]any)
 	}
 	x.store[key] = val
 }
@@ -553,7 +653,11 @@ func (x *contextImpl) Validate(i any) error {
 	if x.slim.Validator == nil {
 		return ErrValidatorNotRegistered
 	}
-	return x.slim.Validator.Validate(i)
+	err := x.slim.Validator.Validate(i)
+	if ve, ok := err.(ValidationErrors); ok {
+		return ve.HTTPError()
+	}
+	return err
 }
 
 // Written returns whether the context response has been written to
@@ -561,6 +665,27 @@ func (x *contextImpl) Written() bool {
 	return x.response.Written()
 }
 
+// CheckPreconditions sets the `ETag` and `Last-Modified` validators and
+// evaluates the conditional request headers against them.
+func (x *contextImpl) CheckPreconditions(etag string, lastModified time.Time) (bool, error) {
+	header := x.response.Header()
+	etag = quoteETag(etag)
+	if etag != "" {
+		header.Set(HeaderETag, etag)
+	}
+	if !isZeroTime(lastModified) {
+		header.Set(HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+	}
+	switch checkPreconditions(x.request, etag, lastModified) {
+	case http.StatusNotModified:
+		writeNotModified(x.response)
+		return true, nil
+	case http.StatusPreconditionFailed:
+		return true, ErrPreconditionFailed
+	}
+	return false, nil
+}
+
 func (x *contextImpl) writeContentType(value string) {
 	if value != "" {
 		header := x.response.Header()
@@ -690,6 +815,149 @@ func (x *contextImpl) Blob(code int, contentType string, b []byte) error {
 	return err
 }
 
+// Respond sends a response encoded with the negotiated codec.
+func (x *contextImpl) Respond(code int, i any) error {
+	types := x.slim.Codecs.MediaTypes()
+	x.Vary(HeaderAccept)
+	var mediaType string
+	if accept := x.request.Header.Get(HeaderAccept); accept != "" {
+		mediaType = x.slim.negotiator.Accepts(accept, types...)
+	} else if len(types) > 0 {
+		mediaType = types[0]
+	}
+	if mediaType == "" {
+		return ErrNotAcceptable
+	}
+	return x.encode(code, mediaType, i)
+}
+
+// Negotiate sends data in the representation among offers that best matches
+// the Accept header.
+func (x *contextImpl) Negotiate(code int, data any, offers ...string) error {
+	if len(offers) == 0 {
+		offers = x.slim.Codecs.MediaTypes()
+	}
+	var template string
+	names := make([]string, 0, len(offers))
+	for _, offer := range offers {
+		switch {
+		case offer == "jsonp":
+			if !x.hasJSONPCallback() {
+				continue
+			}
+		case offer == "html" || strings.HasPrefix(offer, "html:"):
+			if slices.Contains(names, "html") {
+				continue
+			}
+			template, offer = strings.TrimPrefix(offer[len("html"):], ":"), "html"
+		case offer == "json", offer == "xml", offer == "text", offer == "string":
+		default:
+			if _, _, ok := x.slim.Codecs.Lookup(offerMediaTypes(offer)[0]); !ok {
+				continue
+			}
+		}
+		names = append(names, offer)
+	}
+
+	x.Vary(HeaderAccept)
+	var name string
+	if x.request.Header.Get(HeaderAccept) != "" {
+		name = x.slim.negotiator.Type(x.request, names...)
+	} else if len(names) > 0 {
+		name = names[0]
+	}
+	switch name {
+	case "":
+		return ErrNotAcceptable
+	case "json":
+		return x.JSON(code, data)
+	case "xml":
+		return x.XML(code, data)
+	case "jsonp":
+		callback, err := x.jsonpCallback()
+		if err != nil {
+			return err
+		}
+		return x.JSONP(code, callback, data)
+	case "text", "string":
+		return x.String(code, fmt.Sprint(data))
+	case "html":
+		if template != "" {
+			return x.Render(code, template, data)
+		}
+		return x.HTML(code, fmt.Sprint(data))
+	}
+	return x.encode(code, offerMediaTypes(name)[0], data)
+}
+
+// encode sends i encoded with the codec registered for mediaType.
+func (x *contextImpl) encode(code int, mediaType string, i any) error {
+	switch mediaType {
+	case MIMEApplicationJSON:
+		return x.JSON(code, i)
+	case MIMEApplicationXML:
+		return x.XML(code, i)
+	case MIMETextXML:
+		x.writeContentType(MIMETextXMLCharsetUTF8)
+		return x.XML(code, i)
+	}
+	codec, _, ok := x.slim.Codecs.Lookup(mediaType)
+	if !ok {
+		return fmt.Errorf("slim: no codec registered for %q", mediaType)
+	}
+	x.writeContentType(mediaType)
+	x.response.WriteHeader(code)
+	return codec.Encode(x.response, i, x.prettyIndent())
+}
+
+// jsonpCallback returns the JSONP callback named by the first query parameter
+// of `Slim.JSONPCallbacks` that is set. The callback must be a JavaScript
+// identifier or a dotted path of identifiers, so it cannot inject script.
+func (x *contextImpl) jsonpCallback() (string, error) {
+	for _, param := range x.slim.JSONPCallbacks {
+		callback := x.QueryParam(param)
+		if callback == "" {
+			continue
+		}
+		if !isJSONPCallback(callback) {
+			return "", NewHTTPError(http.StatusBadRequest, "Invalid JSONP callback")
+		}
+		return callback, nil
+	}
+	return "", nil
+}
+
+// hasJSONPCallback reports whether a query parameter of `Slim.JSONPCallbacks`
+// is set.
+func (x *contextImpl) hasJSONPCallback() bool {
+	for _, param := range x.slim.JSONPCallbacks {
+		if x.QueryParam(param) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSONPCallback(s string) bool {
+	if len(s) > 128 {
+		return false
+	}
+	for _, ident := range strings.Split(s, ".") {
+		if ident == "" {
+			return false
+		}
+		for i, c := range ident {
+			switch {
+			case c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			case i > 0 && c >= '0' && c <= '9':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // Stream sends a streaming response with status code and content type.
 func (x *contextImpl) Stream(code int, contentType string, r io.Reader) error {
 	x.writeContentType(contentType)
@@ -698,6 +966,13 @@ func (x *contextImpl) Stream(code int, contentType string, r io.Reader) error {
 	return err
 }
 
+// StreamRange sends the content of r with support for range requests.
+func (x *contextImpl) StreamRange(contentType, name string, modtime time.Time, r io.ReaderAt, size int64) error {
+	x.writeContentType(contentType)
+	http.ServeContent(x.response, x.request, name, modtime, io.NewSectionReader(r, 0, size))
+	return nil
+}
+
 // The File sends a response with the content of the file.
 func (x *contextImpl) File(file string, filesystem ...fs.FS) error {
 	var lfs fs.FS
@@ -0,0 +1,153 @@
+package slim
+
+import (
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serveStreamRange(t *testing.T, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := New()
+	s.GET("/media", func(c Context) error {
+		c.SetHeader(HeaderETag, `"v1"`)
+		return c.StreamRange("", "clip.txt", modtime, strings.NewReader("0123456789"), 10)
+	})
+	r := httptest.NewRequest(http.MethodGet, "/media", nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestContext_StreamRange(t *testing.T) {
+	w := serveStreamRange(t, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("code=%d body=%q", w.Code, w.Body.String())
+	}
+	if w.Header().Get(HeaderAcceptRanges) != "bytes" || !strings.HasPrefix(w.Header().Get(HeaderContentType), "text/plain") {
+		t.Fatalf("unexpected headers: %v", w.Header())
+	}
+
+	w = serveStreamRange(t, map[string]string{HeaderRange: "bytes=2-4"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "234" {
+		t.Fatalf("code=%d body=%q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(HeaderContentRange); got != "bytes 2-4/10" {
+		t.Fatalf("content-range=%q", got)
+	}
+
+	w = serveStreamRange(t, map[string]string{HeaderRange: "bytes=0-1,-2"})
+	mt, params, _ := mime.ParseMediaType(w.Header().Get(HeaderContentType))
+	if w.Code != http.StatusPartialContent || mt != "multipart/byteranges" || params["boundary"] == "" {
+		t.Fatalf("code=%d content-type=%q", w.Code, w.Header().Get(HeaderContentType))
+	}
+	if body := w.Body.String(); !strings.Contains(body, "bytes 0-1/10") || !strings.Contains(body, "bytes 8-9/10") {
+		t.Fatalf("body=%q", body)
+	}
+}
+
+func TestContext_StreamRange_IfRangeAndUnsatisfiable(t *testing.T) {
+	w := serveStreamRange(t, map[string]string{HeaderRange: "bytes=2-4", HeaderIfRange: `"v0"`})
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("stale If-Range must send the full content, code=%d", w.Code)
+	}
+
+	w = serveStreamRange(t, map[string]string{HeaderRange: "bytes=2-4", HeaderIfRange: `"v1"`})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("matching If-Range must send the range, code=%d", w.Code)
+	}
+
+	w = serveStreamRange(t, map[string]string{HeaderRange: "bytes=20-30"})
+	if w.Code != http.StatusRequestedRangeNotSatisfiable || w.Header().Get(HeaderContentRange) != "bytes */10" {
+		t.Fatalf("code=%d content-range=%q", w.Code, w.Header().Get(HeaderContentRange))
+	}
+
+	w = serveStreamRange(t, map[string]string{HeaderIfNoneMatch: `"v1"`})
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("code=%d", w.Code)
+	}
+}
+
+func TestContext_Negotiate(t *testing.T) {
+	s := New()
+	s.Debug = false
+	s.ErrorHandler = func(c Context, err error) {
+		if he, ok := err.(*HTTPError); ok {
+			c.NoContent(he.Code)
+		}
+	}
+	ft := &fakeTmpl{}
+	s.Renderer = &TemplateRenderer{Template: ft}
+	s.GET("/", func(c Context) error {
+		return c.Negotiate(http.StatusOK, "hi", "json", "xml", "jsonp", "html:page", "text", "msgpack")
+	})
+	s.GET("/codecs", func(c Context) error {
+		return c.Negotiate(http.StatusOK, map[string]int{"a": 1})
+	})
+	do := func(target, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if accept != "" {
+			r.Header.Set(HeaderAccept, accept)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	cases := []struct {
+		target, accept, ctype, body string
+	}{
+		{"/", "", MIMEApplicationJSONCharsetUTF8, "\"hi\"\n"},
+		{"/", "application/xml", MIMEApplicationXMLCharsetUTF8, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<string>hi</string>"},
+		{"/", "text/html;q=0.9, text/plain;q=0.5", MIMETextHTMLCharsetUTF8, "ok"},
+		{"/", "text/plain", MIMETextPlainCharsetUTF8, "hi"},
+		{"/", "application/msgpack", MIMEApplicationMsgpack, "\xa2hi"},
+		{"/?callback=app.cb", "application/javascript", MIMEApplicationJavaScriptCharsetUTF8, "app.cb(\"hi\"\n);"},
+		{"/codecs", "application/cbor", MIMEApplicationCBOR, "\xa1\x61a\x01"},
+	}
+	for _, c := range cases {
+		w := do(c.target, c.accept)
+		if w.Code != http.StatusOK || w.Header().Get(HeaderContentType) != c.ctype || w.Body.String() != c.body {
+			t.Errorf("%s %q: %d %q %q", c.target, c.accept, w.Code, w.Header().Get(HeaderContentType), w.Body.String())
+		}
+		if w.Header().Get(HeaderVary) != HeaderAccept {
+			t.Errorf("%s %q: vary=%q", c.target, c.accept, w.Header().Get(HeaderVary))
+		}
+	}
+	if ft.lastName != "page" || ft.lastData != "hi" {
+		t.Fatalf("template called with %q %v", ft.lastName, ft.lastData)
+	}
+
+	// jsonp is only offered with a callback parameter
+	if w := do("/", "application/javascript"); w.Code != http.StatusNotAcceptable {
+		t.Fatalf("jsonp without callback: %d", w.Code)
+	}
+	if w := do("/?jsonp=alert(1)", "application/javascript"); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid callback: %d", w.Code)
+	}
+	if w := do("/", "image/png"); w.Code != http.StatusNotAcceptable {
+		t.Fatalf("no match: %d", w.Code)
+	}
+	// the callback is not checked when JSONP is not chosen
+	if w := do("/?jsonp=alert(1)", "application/json"); w.Code != http.StatusOK || w.Body.String() != "\"hi\"\n" {
+		t.Fatalf("json with invalid callback: %d %q", w.Code, w.Body.String())
+	}
+
+	// offers without a codec are ignored, the first html offer wins
+	s.GET("/offers", func(c Context) error {
+		return c.Negotiate(http.StatusOK, "hi", "application/x-unknown", "html:first", "html:second", "json")
+	})
+	if w := do("/offers", "application/x-unknown"); w.Code != http.StatusNotAcceptable {
+		t.Fatalf("unknown codec: %d", w.Code)
+	}
+	if w := do("/offers", ""); w.Code != http.StatusOK || ft.lastName != "first" {
+		t.Fatalf("html offers: %d template=%q", w.Code, ft.lastName)
+	}
+}
@@ -13,7 +13,9 @@ var (
 	ErrUnauthorized                = NewHTTPError(http.StatusUnauthorized)
 	ErrForbidden                   = NewHTTPError(http.StatusForbidden)
 	ErrMethodNotAllowed            = NewHTTPError(http.StatusMethodNotAllowed)
+	ErrNotAcceptable               = NewHTTPError(http.StatusNotAcceptable)
 	ErrStatusRequestEntityTooLarge = NewHTTPError(http.StatusRequestEntityTooLarge)
+	ErrPreconditionFailed          = NewHTTPError(http.StatusPreconditionFailed)
 	ErrTooManyRequests             = NewHTTPError(http.StatusTooManyRequests)
 	ErrBadRequest                  = NewHTTPError(http.StatusBadRequest)
 	ErrBadGateway                  = NewHTTPError(http.StatusBadGateway)
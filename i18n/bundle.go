@@ -0,0 +1,267 @@
+// Package i18n provides message catalogs with CLDR plural rules and a
+// middleware resolving the locale of each request.
+//
+// Catalogs are loaded from an `fs.FS`, one file per locale named after its
+// BCP 47 tag, such as "en.json" or "zh-CN.toml". Nested tables flatten into
+// dotted keys, and a table whose keys are CLDR plural categories ("zero",
+// "one", "two", "few", "many" and "other") is a plural message:
+//
+//	# en.toml
+//	title = "Welcome"
+//
+//	[cart.items]
+//	one = "%d item"
+//	other = "%d items"
+//
+// A Bundle implements `slim.Translator`:
+//
+//	bundle := i18n.NewBundle("en")
+//	if err := bundle.LoadFS(locales); err != nil {
+//		log.Fatal(err)
+//	}
+//	s.Translator = bundle
+//	s.Use(i18n.Middleware(bundle))
+//	s.GET("/", func(c slim.Context) error {
+//		return c.String(http.StatusOK, c.T("cart.items", 3))
+//	})
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// Bundle holds the message catalogs of all supported locales. It is safe
+// for concurrent use.
+type Bundle struct {
+	defaultLocale string
+	mu            sync.RWMutex
+	// catalogs maps a lowercase locale to its messages
+	catalogs map[string]map[string]message
+	// locales lists the supported locales in the order they were added
+	locales []string
+}
+
+// message is a translation, plural messages hold a form per plural category.
+type message struct {
+	text   string
+	plural map[string]string
+}
+
+// NewBundle returns an empty bundle, defaultLocale is used when a message is
+// missing in the requested locale.
+func NewBundle(defaultLocale string) *Bundle {
+	return &Bundle{
+		defaultLocale: canonicalLocale(defaultLocale),
+		catalogs:      make(map[string]map[string]message),
+	}
+}
+
+// DefaultLocale returns the default locale of the bundle.
+func (b *Bundle) DefaultLocale() string {
+	return b.defaultLocale
+}
+
+// Locales returns the locales that have a catalog, in the order they were
+// added.
+func (b *Bundle) Locales() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return slices.Clone(b.locales)
+}
+
+// LoadFS loads the catalog files of fsys matching the glob patterns, all the
+// files of the root directory when no pattern is given. The file name without
+// extension is the locale, the extension selects the format: ".json" or
+// ".toml". Files with other extensions are ignored.
+func (b *Bundle) LoadFS(fsys fs.FS, patterns ...string) error {
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			ext := path.Ext(name)
+			if ext != ".json" && ext != ".toml" {
+				continue
+			}
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			if err = b.Parse(strings.TrimSuffix(path.Base(name), ext), ext[1:], data); err != nil {
+				return fmt.Errorf("i18n: %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Parse adds the messages of a catalog in format "json" or "toml" to
+// locale.
+func (b *Bundle) Parse(locale, format string, data []byte) error {
+	var messages map[string]any
+	switch format {
+	case "json":
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return err
+		}
+	case "toml":
+		var err error
+		if messages, err = parseTOML(data); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported catalog format %q", format)
+	}
+	return b.AddMessages(locale, messages)
+}
+
+// AddMessages adds messages to locale. Values are strings, nested maps whose
+// keys are joined with dots, or maps of plural categories to strings.
+func (b *Bundle) AddMessages(locale string, messages map[string]any) error {
+	flat := make(map[string]message)
+	if err := flatten(flat, "", messages); err != nil {
+		return err
+	}
+	locale = canonicalLocale(locale)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	catalog, ok := b.catalogs[strings.ToLower(locale)]
+	if !ok {
+		catalog = make(map[string]message, len(flat))
+		b.catalogs[strings.ToLower(locale)] = catalog
+		b.locales = append(b.locales, locale)
+	}
+	for key, msg := range flat {
+		catalog[key] = msg
+	}
+	return nil
+}
+
+func flatten(dst map[string]message, prefix string, messages map[string]any) error {
+	for key, value := range messages {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case string:
+			dst[key] = message{text: v}
+		case map[string]any:
+			if forms, ok := pluralForms(v); ok {
+				dst[key] = message{plural: forms}
+			} else if err := flatten(dst, key, v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %q has unsupported type %T", key, value)
+		}
+	}
+	return nil
+}
+
+// pluralForms reports whether m is a plural message: a map of plural
+// categories to strings that has the required "other" form.
+func pluralForms(m map[string]any) (map[string]string, bool) {
+	if _, ok := m["other"]; !ok {
+		return nil, false
+	}
+	forms := make(map[string]string, len(m))
+	for category, value := range m {
+		s, ok := value.(string)
+		if !ok || !isPluralCategory(category) {
+			return nil, false
+		}
+		forms[category] = s
+	}
+	return forms, true
+}
+
+// Translate returns the message for key in locale, falling back to the
+// parent locales (en-GB falls back to en) and then to the default locale. It
+// returns key when no catalog has the message.
+//
+// For plural messages the first numeric argument selects the plural form.
+// Messages containing a "%" are formatted with `fmt.Sprintf` and args.
+func (b *Bundle) Translate(locale, key string, args ...any) string {
+	msg, found, ok := b.lookup(locale, key)
+	if !ok {
+		return key
+	}
+	text := msg.text
+	if msg.plural != nil {
+		category := "other"
+		for _, arg := range args {
+			if n, ok := pluralOperand(arg); ok {
+				category = pluralCategory(found, n)
+				break
+			}
+		}
+		var ok bool
+		if text, ok = msg.plural[category]; !ok {
+			text = msg.plural["other"]
+		}
+	}
+	if len(args) > 0 && strings.Contains(text, "%") {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// lookup finds key in locale, its parents or the default locale, and returns
+// the locale it was found in.
+func (b *Bundle) lookup(locale, key string) (message, string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, candidate := range []string{canonicalLocale(locale), b.defaultLocale} {
+		for candidate != "" {
+			if msg, ok := b.catalogs[strings.ToLower(candidate)][key]; ok {
+				return msg, candidate, true
+			}
+			i := strings.LastIndexByte(candidate, '-')
+			if i < 0 {
+				break
+			}
+			candidate = candidate[:i]
+		}
+	}
+	return message{}, "", false
+}
+
+// canonicalLocale returns the canonical form of a BCP 47 tag, or the trimmed
+// input when it is not a valid tag.
+func canonicalLocale(locale string) string {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	if tag, err := language.Parse(locale); err == nil {
+		return tag.String()
+	}
+	return locale
+}
+
+// pluralOperand returns the absolute value of a numeric argument as a
+// decimal string, which keeps the visible fraction digits the plural rules
+// depend on.
+func pluralOperand(arg any) (string, bool) {
+	v := reflect.ValueOf(arg)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strings.TrimPrefix(strconv.FormatInt(v.Int(), 10), "-"), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strings.TrimPrefix(strconv.FormatFloat(v.Float(), 'f', -1, 64), "-"), true
+	}
+	return "", false
+}
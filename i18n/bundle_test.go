@@ -0,0 +1,103 @@
+package i18n
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+var testLocales = fstest.MapFS{
+	"en.json": {Data: []byte(`{
+		"title": "Welcome",
+		"greeting": "Hello, %s!",
+		"cart": {"items": {"one": "%d item", "other": "%d items"}, "empty": "Empty"}
+	}`)},
+	"en-GB.toml": {Data: []byte(`
+# British overrides
+title = "Welcome, mate"
+`)},
+	"ru.toml": {Data: []byte(`
+title = "Добро пожаловать"
+
+[cart.items]
+one = "%d товар"
+few = "%d товара"
+many = "%d товаров"
+other = "%d товара"
+`)},
+	"README.md": {Data: []byte("ignored")},
+}
+
+func TestBundle_LoadAndTranslate(t *testing.T) {
+	b := NewBundle("en")
+	if err := b.LoadFS(testLocales); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Locales(); len(got) != 3 {
+		t.Fatalf("locales=%v", got)
+	}
+	cases := []struct {
+		locale, key string
+		args        []any
+		want        string
+	}{
+		{"en", "title", nil, "Welcome"},
+		{"en_gb", "title", nil, "Welcome, mate"},
+		{"en-GB", "cart.empty", nil, "Empty"},
+		{"en-US", "title", nil, "Welcome"},
+		{"fr", "title", nil, "Welcome"},
+		{"", "greeting", []any{"Ann"}, "Hello, Ann!"},
+		{"en", "cart.items", []any{1}, "1 item"},
+		{"en", "cart.items", []any{2}, "2 items"},
+		{"en", "cart.items", []any{1.5}, "%!d(float64=1.5) items"},
+		{"ru", "cart.items", []any{1}, "1 товар"},
+		{"ru", "cart.items", []any{3}, "3 товара"},
+		{"ru", "cart.items", []any{11}, "11 товаров"},
+		{"ru", "cart.items", []any{22}, "22 товара"},
+		{"ru", "cart.empty", nil, "Empty"},
+		{"en", "missing", nil, "missing"},
+	}
+	for _, c := range cases {
+		if got := b.Translate(c.locale, c.key, c.args...); got != c.want {
+			t.Errorf("Translate(%q, %q, %v) = %q, want %q", c.locale, c.key, c.args, got, c.want)
+		}
+	}
+}
+
+func TestBundle_Errors(t *testing.T) {
+	b := NewBundle("en")
+	if err := b.Parse("en", "json", []byte(`{"a": 1}`)); err == nil {
+		t.Error("expected unsupported value error")
+	}
+	if err := b.Parse("en", "yaml", nil); err == nil {
+		t.Error("expected unsupported format error")
+	}
+	for _, src := range []string{
+		"a = 1",
+		"a = \"x",
+		"a \"x\"",
+		"[a\nb = \"x\"",
+		"a = \"x\"\na = \"y\"",
+		"a = \"x\"\n[a]",
+		"a = \"x\" junk",
+	} {
+		if err := b.Parse("en", "toml", []byte(src)); err == nil {
+			t.Errorf("expected error for %q", src)
+		}
+	}
+	if err := b.LoadFS(fstest.MapFS{"en.json": {Data: []byte("{")}}); err == nil {
+		t.Error("expected load error")
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	m, err := parseTOML([]byte("\uFEFF# comment\na.b = 'lit\\eral' # trailing\n\"quoted key\" = \"tab\\tnew # not a comment\"\n[c . d]\ne = \"x\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["a"].(map[string]any)["b"] != `lit\eral` || m["quoted key"] != "tab\tnew # not a comment" {
+		t.Fatalf("m=%v", m)
+	}
+	if m["c"].(map[string]any)["d"].(map[string]any)["e"] != "x" {
+		t.Fatalf("m=%v", m)
+	}
+}
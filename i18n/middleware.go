@@ -0,0 +1,140 @@
+package i18n
+
+import (
+	"net/http"
+	"strings"
+
+	"go-slim.dev/slim"
+)
+
+// Config defines the config for the locale middleware.
+type Config struct {
+	// Bundle provides the supported locales.
+	// Required.
+	Bundle *Bundle
+
+	// QueryParam is the query parameter holding the requested locale, "-"
+	// disables it.
+	// Optional. Default value "lang".
+	QueryParam string
+
+	// CookieName is the cookie holding the requested locale, "-" disables it.
+	// Optional. Default value "lang".
+	CookieName string
+
+	// PathPrefix takes the locale from the first segment of the request path,
+	// such as "/en-US/about". The segment must name a supported locale, it is
+	// then removed from the path so that routes do not repeat it: "/about"
+	// handles "/en-US/about". Register the middleware with `Slim.Use`, which
+	// runs before routing, for the routes to see the stripped path.
+	// Optional. Default value false.
+	PathPrefix bool
+}
+
+// Middleware returns a middleware resolving the request locale among the
+// locales of bundle.
+func Middleware(bundle *Bundle) slim.MiddlewareFunc {
+	return MiddlewareWithConfig(Config{Bundle: bundle})
+}
+
+// MiddlewareWithConfig returns a middleware that resolves the request locale
+// and stores it with `Context.SetLocale`. The locale is taken, in order, from
+// the query parameter, the cookie, the path prefix and the Accept-Language
+// header, each matched against the supported locales with the BCP 47 rules of
+// `Negotiator.Language`; the default locale of the bundle is used when none
+// matches. The resolved locale is sent in the Content-Language header.
+func MiddlewareWithConfig(config Config) slim.MiddlewareFunc {
+	if config.Bundle == nil {
+		panic("i18n: middleware requires a bundle")
+	}
+	if config.QueryParam == "" {
+		config.QueryParam = "lang"
+	}
+	if config.CookieName == "" {
+		config.CookieName = "lang"
+	}
+
+	return func(c slim.Context, next slim.HandlerFunc) error {
+		var pathLocale string
+		if config.PathPrefix {
+			pathLocale = stripLocalePrefix(c.Request(), config.Bundle.Locales())
+		}
+		locale := resolveLocale(c, config, pathLocale)
+		c.SetLocale(locale)
+		if locale != "" {
+			c.SetHeader(slim.HeaderContentLanguage, locale)
+		}
+		return next(c)
+	}
+}
+
+// stripLocalePrefix removes the first segment of the request path when it
+// names one of locales, and returns that locale.
+func stripLocalePrefix(r *http.Request, locales []string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if segment == "" {
+		return ""
+	}
+	for _, locale := range locales {
+		if strings.EqualFold(canonicalLocale(segment), locale) {
+			r.URL.Path = trimFirstSegment(r.URL.Path)
+			if r.URL.RawPath != "" {
+				r.URL.RawPath = trimFirstSegment(r.URL.RawPath)
+			}
+			return locale
+		}
+	}
+	return ""
+}
+
+// trimFirstSegment turns "/en-US/about" into "/about" and "/en-US" into "/".
+func trimFirstSegment(p string) string {
+	_, rest, _ := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+	return "/" + rest
+}
+
+func resolveLocale(c slim.Context, config Config, pathLocale string) string {
+	locales := config.Bundle.Locales()
+	negotiator := c.Slim().Negotiator()
+	if config.QueryParam != "-" {
+		if v := c.QueryParam(config.QueryParam); v != "" {
+			if locale := negotiator.MatchLanguage(v, locales...); locale != "" {
+				return locale
+			}
+		}
+	}
+	if config.CookieName != "-" {
+		if cookie, err := c.Cookie(config.CookieName); err == nil && cookie.Value != "" {
+			if locale := negotiator.MatchLanguage(cookie.Value, locales...); locale != "" {
+				return locale
+			}
+		}
+	}
+	if pathLocale != "" {
+		return pathLocale
+	}
+	c.Vary(slim.HeaderAcceptLanguage)
+	if c.Header(slim.HeaderAcceptLanguage) != "" {
+		if locale := c.AcceptsLanguages(locales...); locale != "" {
+			return locale
+		}
+	}
+	return config.Bundle.DefaultLocale()
+}
+
+// Funcs returns the template functions "t", which translates a key in the
+// request locale, and "locale", which returns the request locale. Use it as
+// `slim.TemplateRenderer.Funcs`, and parse the templates with Funcs(nil),
+// which returns placeholders for the same names.
+func Funcs(c slim.Context) map[string]any {
+	if c == nil {
+		return map[string]any{
+			"t":      func(key string, _ ...any) string { return key },
+			"locale": func() string { return "" },
+		}
+	}
+	return map[string]any{
+		"t":      c.T,
+		"locale": c.Locale,
+	}
+}
@@ -0,0 +1,103 @@
+package i18n
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-slim.dev/slim"
+)
+
+func newTestServer(t *testing.T, config Config) *slim.Slim {
+	t.Helper()
+	b := NewBundle("en")
+	if err := b.LoadFS(testLocales); err != nil {
+		t.Fatal(err)
+	}
+	config.Bundle = b
+	s := slim.New()
+	s.Translator = b
+	s.Renderer = &slim.TemplateRenderer{
+		Template: template.Must(template.New("page").Funcs(Funcs(nil)).Parse(`{{locale}}: {{t "title"}} ({{t "cart.items" 2}})`)),
+		Funcs:    Funcs,
+	}
+	s.Use(MiddlewareWithConfig(config))
+	handler := func(c slim.Context) error {
+		return c.String(http.StatusOK, c.Locale()+": "+c.T("title"))
+	}
+	s.GET("/", handler)
+	s.GET("/about", func(c slim.Context) error {
+		return c.String(http.StatusOK, c.Locale()+": "+c.T("title")+" at "+c.Request().URL.Path)
+	})
+	s.GET("/:lang/about", handler)
+	s.GET("/page", func(c slim.Context) error { return c.Render(http.StatusOK, "page", nil) })
+	return s
+}
+
+func TestMiddleware_ResolveLocale(t *testing.T) {
+	s := newTestServer(t, Config{PathPrefix: true})
+	cases := []struct {
+		target, cookie, accept string
+		want                   string
+	}{
+		{"/", "", "", "en: Welcome"},
+		{"/", "", "ru;q=0.9, en-GB", "en-GB: Welcome, mate"},
+		{"/", "", "fr, ru;q=0.5", "ru: Добро пожаловать"},
+		{"/", "", "de", "en: Welcome"},
+		{"/", "ru", "en-GB", "ru: Добро пожаловать"},
+		{"/?lang=en-gb", "ru", "", "en-GB: Welcome, mate"},
+		{"/?lang=de", "ru", "", "ru: Добро пожаловать"},
+		// the locale prefix is removed before routing
+		{"/ru/about", "", "en-GB", "ru: Добро пожаловать at /about"},
+		{"/ru/about?lang=en-GB", "", "", "en-GB: Welcome, mate at /about"},
+		{"/de/about", "", "", "en: Welcome"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.target, nil)
+		if c.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "lang", Value: c.cookie})
+		}
+		if c.accept != "" {
+			req.Header.Set(slim.HeaderAcceptLanguage, c.accept)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Body.String() != c.want {
+			t.Errorf("%s cookie=%q accept=%q: got %q, want %q", c.target, c.cookie, c.accept, rec.Body.String(), c.want)
+		}
+		if lang := rec.Header().Get(slim.HeaderContentLanguage); lang == "" {
+			t.Errorf("%s: missing Content-Language", c.target)
+		}
+	}
+}
+
+func TestMiddleware_Disabled(t *testing.T) {
+	s := newTestServer(t, Config{QueryParam: "-", CookieName: "-"})
+	req := httptest.NewRequest(http.MethodGet, "/ru/about?lang=ru", nil)
+	req.AddCookie(&http.Cookie{Name: "lang", Value: "ru"})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Body.String() != "en: Welcome" {
+		t.Fatalf("got %q", rec.Body.String())
+	}
+}
+
+func TestMiddleware_TemplateFuncs(t *testing.T) {
+	s := newTestServer(t, Config{})
+	req := httptest.NewRequest(http.MethodGet, "/page?lang=ru", nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if want := "ru: Добро пожаловать (2 товара)"; rec.Body.String() != want {
+		t.Fatalf("got %q, want %q", rec.Body.String(), want)
+	}
+}
+
+func TestMiddleware_RequiresBundle(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	Middleware(nil)
+}
@@ -0,0 +1,201 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CLDR plural categories
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+func isPluralCategory(s string) bool {
+	switch s {
+	case PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther:
+		return true
+	}
+	return false
+}
+
+// PluralCategory returns the CLDR cardinal plural category of the number n
+// in locale. Languages without known rules, and non-numeric values of n,
+// always use PluralOther.
+func PluralCategory(locale string, n any) string {
+	s, ok := pluralOperand(n)
+	if !ok {
+		return PluralOther
+	}
+	return pluralCategory(canonicalLocale(locale), s)
+}
+
+// pluralCategory returns the plural category of the non-negative decimal
+// number s.
+func pluralCategory(locale, s string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	if strings.EqualFold(locale, "pt-PT") {
+		return pluralOneInteger(newOperands(s))
+	}
+	rule, ok := pluralRules[lang]
+	if !ok {
+		return PluralOther
+	}
+	return rule(newOperands(s))
+}
+
+// operands are the plural operands of a number, see
+// https://unicode.org/reports/tr35/tr35-numbers.html#Operands.
+type operands struct {
+	n float64 // absolute value
+	i uint64  // integer digits
+	v int     // number of visible fraction digits
+	f uint64  // visible fraction digits
+	// i%10 and i%100, kept apart as i saturates for very large numbers
+	i10, i100 uint64
+}
+
+func newOperands(s string) operands {
+	var o operands
+	o.n, _ = strconv.ParseFloat(s, 64)
+	intPart, frac, _ := strings.Cut(s, ".")
+	o.i, _ = strconv.ParseUint(intPart, 10, 64)
+	o.v = len(frac)
+	o.f, _ = strconv.ParseUint(frac, 10, 64)
+	tail := intPart[max(0, len(intPart)-2):]
+	o.i100, _ = strconv.ParseUint(tail, 10, 64)
+	o.i10 = o.i100 % 10
+	return o
+}
+
+func inRange(x, lo, hi uint64) bool { return x >= lo && x <= hi }
+
+// pluralRules maps a language to its cardinal plural rule.
+var pluralRules = map[string]func(o operands) string{}
+
+func init() {
+	register := func(rule func(o operands) string, langs ...string) {
+		for _, lang := range langs {
+			pluralRules[lang] = rule
+		}
+	}
+	register(func(operands) string { return PluralOther },
+		"ja", "zh", "ko", "vi", "th", "id", "ms", "lo", "my", "km", "yo", "jv", "su", "bo", "dz", "ig", "sah", "to", "wo")
+	register(pluralOneInteger,
+		"en", "de", "nl", "sv", "da", "fi", "et", "it", "ca", "gl", "fy", "ia", "io", "sc", "sw", "ur", "yi", "ast", "lij", "vec")
+	register(pluralOneN,
+		"es", "el", "hu", "tr", "bg", "nb", "nn", "no", "af", "az", "eo", "eu", "ka", "kk", "ky", "mn", "ne", "sq", "ta", "te", "uz", "ml", "ps", "so", "tk", "ug")
+	register(func(o operands) string {
+		if o.i <= 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}, "fr", "pt", "hy", "kab", "ff")
+	register(func(o operands) string {
+		if o.i == 0 || o.n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}, "hi", "bn", "fa", "gu", "kn", "mr", "zu", "am", "as")
+	register(pluralEastSlavic, "ru", "uk", "be")
+	register(pluralSouthSlavic, "hr", "sr", "bs", "sh")
+	register(func(o operands) string {
+		switch {
+		case o.i == 1 && o.v == 0:
+			return PluralOne
+		case o.v == 0 && inRange(o.i10, 2, 4) && !inRange(o.i100, 12, 14):
+			return PluralFew
+		case o.v == 0 && (o.i10 <= 1 || inRange(o.i10, 5, 9) || inRange(o.i100, 12, 14)):
+			return PluralMany
+		}
+		return PluralOther
+	}, "pl")
+	register(func(o operands) string {
+		switch {
+		case o.i == 1 && o.v == 0:
+			return PluralOne
+		case inRange(o.i, 2, 4) && o.v == 0:
+			return PluralFew
+		case o.v != 0:
+			return PluralMany
+		}
+		return PluralOther
+	}, "cs", "sk")
+	register(func(o operands) string {
+		switch {
+		case o.i == 1 && o.v == 0:
+			return PluralOne
+		case o.v != 0 || o.n == 0 || o.n != 1 && inRange(o.i100, 1, 19):
+			return PluralFew
+		}
+		return PluralOther
+	}, "ro", "mo")
+	register(func(o operands) string {
+		switch {
+		case o.i == 1 && o.v == 0, o.i == 0 && o.v != 0:
+			return PluralOne
+		case o.i == 2 && o.v == 0:
+			return PluralTwo
+		}
+		return PluralOther
+	}, "he", "iw")
+	register(func(o operands) string {
+		switch {
+		case o.n == 0:
+			return PluralZero
+		case o.n == 1:
+			return PluralOne
+		case o.n == 2:
+			return PluralTwo
+		case o.v == 0 && inRange(o.i100, 3, 10):
+			return PluralFew
+		case o.v == 0 && inRange(o.i100, 11, 99):
+			return PluralMany
+		}
+		return PluralOther
+	}, "ar", "ars")
+}
+
+// pluralOneInteger: one is 1 without visible fraction digits, as in English.
+func pluralOneInteger(o operands) string {
+	if o.i == 1 && o.v == 0 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+// pluralOneN: one is exactly 1, "1.0" included.
+func pluralOneN(o operands) string {
+	if o.n == 1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+func pluralEastSlavic(o operands) string {
+	switch {
+	case o.v != 0:
+		return PluralOther
+	case o.i10 == 1 && o.i100 != 11:
+		return PluralOne
+	case inRange(o.i10, 2, 4) && !inRange(o.i100, 12, 14):
+		return PluralFew
+	}
+	return PluralMany
+}
+
+func pluralSouthSlavic(o operands) string {
+	f10, f100 := o.f%10, o.f%100
+	switch {
+	case o.v == 0 && o.i10 == 1 && o.i100 != 11, f10 == 1 && f100 != 11:
+		return PluralOne
+	case o.v == 0 && inRange(o.i10, 2, 4) && !inRange(o.i100, 12, 14),
+		inRange(f10, 2, 4) && !inRange(f100, 12, 14):
+		return PluralFew
+	}
+	return PluralOther
+}
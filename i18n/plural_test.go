@@ -0,0 +1,56 @@
+package i18n
+
+import "testing"
+
+func TestPluralCategory(t *testing.T) {
+	cases := []struct {
+		locale string
+		n      any
+		want   string
+	}{
+		{"en", 1, PluralOne},
+		{"en", 0, PluralOther},
+		{"en", 1.5, PluralOther},
+		{"en-US", -1, PluralOne},
+		{"es", 1.0, PluralOne},
+		{"fr", 0, PluralOne},
+		{"fr", 1.5, PluralOne},
+		{"fr", 2, PluralOther},
+		{"pt", 0, PluralOne},
+		{"pt-PT", 0, PluralOther},
+		{"ja", 1, PluralOther},
+		{"ru", 21, PluralOne},
+		{"ru", 11, PluralMany},
+		{"ru", 24, PluralFew},
+		{"ru", 25, PluralMany},
+		{"ru", 1.5, PluralOther},
+		{"uk", uint64(112), PluralMany},
+		{"pl", 1, PluralOne},
+		{"pl", 22, PluralFew},
+		{"pl", 12, PluralMany},
+		{"pl", 21, PluralMany},
+		{"cs", 3, PluralFew},
+		{"cs", 0.5, PluralMany},
+		{"cs", 5, PluralOther},
+		{"hr", 21, PluralOne},
+		{"hr", 2.3, PluralFew},
+		{"ro", 0, PluralFew},
+		{"ro", 101, PluralFew},
+		{"ro", 20, PluralOther},
+		{"he", 2, PluralTwo},
+		{"ar", 0, PluralZero},
+		{"ar", 2, PluralTwo},
+		{"ar", 103, PluralFew},
+		{"ar", 111, PluralMany},
+		{"ar", 100, PluralOther},
+		{"hi", 0, PluralOne},
+		{"xx", 1, PluralOther},
+		{"en", "1", PluralOther},
+		{"ru", uint64(18446744073709551611), PluralMany},
+	}
+	for _, c := range cases {
+		if got := PluralCategory(c.locale, c.n); got != c.want {
+			t.Errorf("PluralCategory(%q, %v) = %q, want %q", c.locale, c.n, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML used by catalogs: comments, tables
+// ("[cart.items]"), and key/value pairs whose keys are bare, quoted or
+// dotted and whose values are basic ("...") or literal ('...') strings.
+func parseTOML(data []byte) (map[string]any, error) {
+	root := make(map[string]any)
+	table := root
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if lineno == 1 {
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.LastIndexByte(line, ']')
+			if end < 0 || !isTOMLComment(line[end+1:]) {
+				return nil, fmt.Errorf("line %d: invalid table header", lineno)
+			}
+			path, rest, err := parseTOMLKey(line[1:end])
+			if err != nil || rest != "" {
+				return nil, fmt.Errorf("line %d: invalid table name", lineno)
+			}
+			if table, err = tomlTable(root, path); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineno, err)
+			}
+			continue
+		}
+
+		path, rest, err := parseTOMLKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineno, err)
+		}
+		if !strings.HasPrefix(rest, "=") {
+			return nil, fmt.Errorf("line %d: expected '=' after key", lineno)
+		}
+		value, rest, err := parseTOMLString(strings.TrimSpace(rest[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineno, err)
+		}
+		if !isTOMLComment(rest) {
+			return nil, fmt.Errorf("line %d: unexpected %q after value", lineno, rest)
+		}
+		parent, err := tomlTable(table, path[:len(path)-1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineno, err)
+		}
+		key := path[len(path)-1]
+		if _, exists := parent[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lineno, strings.Join(path, "."))
+		}
+		parent[key] = value
+	}
+	return root, scanner.Err()
+}
+
+func isTOMLComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
+
+// tomlTable returns the table at path below t, creating missing tables.
+func tomlTable(t map[string]any, path []string) (map[string]any, error) {
+	for _, name := range path {
+		switch v := t[name].(type) {
+		case nil:
+			sub := make(map[string]any)
+			t[name] = sub
+			t = sub
+		case map[string]any:
+			t = v
+		default:
+			return nil, fmt.Errorf("key %q is not a table", name)
+		}
+	}
+	return t, nil
+}
+
+// parseTOMLKey parses a possibly dotted key at the start of s and returns its
+// parts and the trimmed remaining input.
+func parseTOMLKey(s string) ([]string, string, error) {
+	var path []string
+	for {
+		s = strings.TrimSpace(s)
+		var part string
+		if s != "" && (s[0] == '"' || s[0] == '\'') {
+			var err error
+			if part, s, err = parseTOMLString(s); err != nil {
+				return nil, "", err
+			}
+		} else {
+			i := 0
+			for i < len(s) && isBareKeyChar(s[i]) {
+				i++
+			}
+			if i == 0 {
+				return nil, "", fmt.Errorf("invalid key")
+			}
+			part, s = s[:i], s[i:]
+		}
+		path = append(path, part)
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, ".") {
+			return path, s, nil
+		}
+		s = s[1:]
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseTOMLString parses the string at the start of s and returns it with
+// the remaining input.
+func parseTOMLString(s string) (string, string, error) {
+	if s == "" {
+		return "", "", fmt.Errorf("missing value")
+	}
+	switch s[0] {
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				value, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+				}
+				return value, s[i+1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string")
+	}
+	return "", "", fmt.Errorf("value must be a string")
+}
@@ -0,0 +1,95 @@
+package slim
+
+import "context"
+
+// storeKey marks typed keys so that `Context.Value` can look them up in the
+// request-scoped store without accepting arbitrary (possibly incomparable) keys.
+type storeKey interface {
+	storeKey()
+}
+
+// Key is a type-safe handle for request-scoped data. Values stored through a
+// Key are visible via `context.Context.Value(key)` on the slim context and on
+// its request context, so code that only has `c.Request().Context()` can read
+// them as well.
+//
+//	var UserKey = slim.NewKey[*User]("user")
+//
+//	UserKey.Set(c, user)    // in an authentication middleware
+//	user := UserKey.Get(c)  // in the handler
+type Key[T any] struct {
+	name string
+}
+
+// NewKey creates a new typed key. Every call returns a distinct key, the
+// name is only used for debugging.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+func (*Key[T]) storeKey() {}
+
+// String implements `fmt.Stringer`.
+func (k *Key[T]) String() string {
+	return "slim key " + k.name
+}
+
+// Name returns the name of the key.
+func (k *Key[T]) Name() string {
+	return k.name
+}
+
+// Get returns the value stored in the context, or the zero value of T if
+// nothing has been stored.
+func (k *Key[T]) Get(c context.Context) T {
+	v, _ := k.Lookup(c)
+	return v
+}
+
+// Lookup returns the value stored in the context and reports whether it
+// was present.
+func (k *Key[T]) Lookup(c context.Context) (T, bool) {
+	var v any
+	var ok bool
+	x, yes := c.(*contextImpl)
+	if !yes {
+		// The request context of a slim request carries the slim context.
+		x, yes = c.Value(ContextKey).(*contextImpl)
+	}
+	if yes {
+		v, ok = x.value(k)
+	}
+	if !ok {
+		v = c.Value(k)
+		ok = v != nil
+	}
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	t, ok := v.(T)
+	return t, ok
+}
+
+// Set stores the value in the context. Custom `Context` implementations fall
+// back to deriving the request context with `context.WithValue`.
+func (k *Key[T]) Set(c Context, val T) {
+	if x, ok := c.(*contextImpl); ok {
+		x.setValue(k, val)
+		return
+	}
+	r := c.Request()
+	c.SetRequest(r.WithContext(context.WithValue(r.Context(), k, val)))
+}
+
+// Delete removes the value from the context.
+func (k *Key[T]) Delete(c Context) {
+	if x, ok := c.(*contextImpl); ok {
+		x.mu.Lock()
+		delete(x.store, k)
+		x.mu.Unlock()
+		return
+	}
+	r := c.Request()
+	c.SetRequest(r.WithContext(context.WithValue(r.Context(), k, nil)))
+}
@@ -0,0 +1,77 @@
+package slim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type keyUser struct{ Name string }
+
+func TestKey_SetGetAndContextValue(t *testing.T) {
+	userKey := NewKey[*keyUser]("user")
+	countKey := NewKey[int]("count")
+
+	s := New()
+	s.Use(func(c Context, next HandlerFunc) error {
+		userKey.Set(c, &keyUser{Name: "alice"})
+		return next(c)
+	})
+	s.GET("/", func(c Context) error {
+		if u := userKey.Get(c); u == nil || u.Name != "alice" {
+			t.Fatalf("user=%v", u)
+		}
+		if u, ok := c.Value(userKey).(*keyUser); !ok || u.Name != "alice" {
+			t.Fatalf("context.Value returned %v", c.Value(userKey))
+		}
+		if u := userKey.Get(c.Request().Context()); u == nil || u.Name != "alice" {
+			t.Fatalf("request context lookup returned %v", u)
+		}
+		if u, ok := c.Request().Context().Value(userKey).(*keyUser); !ok || u.Name != "alice" {
+			t.Fatalf("request context Value returned %v", c.Request().Context().Value(userKey))
+		}
+		if n, ok := countKey.Lookup(c); ok || n != 0 {
+			t.Fatalf("count=%d ok=%v", n, ok)
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d", w.Code)
+	}
+}
+
+func TestKey_DistinctKeysWithSameName(t *testing.T) {
+	a := NewKey[string]("k")
+	b := NewKey[string]("k")
+	c := New().NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	a.Set(c, "a")
+	if _, ok := b.Lookup(c); ok {
+		t.Fatalf("keys with the same name must not collide")
+	}
+	if c.Get("k") != nil {
+		t.Fatalf("typed keys must not collide with string keys")
+	}
+	a.Delete(c)
+	if _, ok := a.Lookup(c); ok {
+		t.Fatalf("value should be deleted")
+	}
+}
+
+func TestContext_ResetKeepsStore(t *testing.T) {
+	s := New()
+	c := s.NewContext(nil, nil).(*contextImpl)
+	c.Reset(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	c.Set("a", 1)
+	store := c.store
+	c.Reset(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if c.Get("a") != nil {
+		t.Fatalf("store should be cleared on reset")
+	}
+	c.Set("b", 2)
+	if len(store) != 1 || store["b"] != 2 {
+		t.Fatalf("store should be reused across requests")
+	}
+}
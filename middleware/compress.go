@@ -0,0 +1,345 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go-slim.dev/slim"
+)
+
+// CompressConfig defines the config for Compress middleware.
+type CompressConfig struct {
+	// Level is the compression level, from flate.HuffmanOnly (-2) to
+	// flate.BestCompression (9). Zero selects flate.DefaultCompression.
+	// Optional. Default value flate.DefaultCompression.
+	Level int
+
+	// MinLength is the smallest response body, in bytes, that is compressed.
+	// Smaller responses are sent as is, compressing them does not pay off.
+	// Optional. Default value 1024.
+	MinLength int
+
+	// SkipTypes lists the media types that are sent as is, usually because
+	// they are already compressed. A "type/*" entry matches all subtypes.
+	// Optional. Default value DefaultCompressConfig.SkipTypes.
+	SkipTypes []string
+}
+
+// DefaultCompressConfig is the default Compress middleware config.
+var DefaultCompressConfig = CompressConfig{
+	Level:     flate.DefaultCompression,
+	MinLength: 1024,
+	SkipTypes: []string{
+		"image/png",
+		"image/jpeg",
+		"image/gif",
+		"image/webp",
+		"image/avif",
+		"image/heic",
+		"video/*",
+		"audio/*",
+		"font/woff",
+		"font/woff2",
+		"application/zip",
+		"application/gzip",
+		"application/x-gzip",
+		"application/zstd",
+		"application/x-bzip2",
+		"application/x-xz",
+		"application/x-7z-compressed",
+		"application/vnd.rar",
+		"application/x-rar-compressed",
+	},
+}
+
+// Compress returns a middleware which compresses response bodies with gzip
+// or deflate, as negotiated from the `Accept-Encoding` request header.
+//
+// Bodies shorter than MinLength, bodies of the media types in SkipTypes,
+// range responses and responses that already have a `Content-Encoding` or
+// carry `Cache-Control: no-transform` are sent as is. Responses flushed
+// before reaching MinLength, such as `c.Stream` used for SSE, are compressed
+// and each flush sends the data compressed so far.
+func Compress() slim.MiddlewareFunc {
+	return CompressWithConfig(DefaultCompressConfig)
+}
+
+// CompressWithConfig returns a Compress middleware with config.
+// See: `Compress()`.
+func CompressWithConfig(config CompressConfig) slim.MiddlewareFunc {
+	return config.ToMiddleware()
+}
+
+// ToMiddleware converts CompressConfig to middleware.
+func (config CompressConfig) ToMiddleware() slim.MiddlewareFunc {
+	if config.Level == 0 {
+		config.Level = DefaultCompressConfig.Level
+	}
+	if config.Level < flate.HuffmanOnly || config.Level > flate.BestCompression {
+		panic("invalid compression level")
+	}
+	if config.MinLength <= 0 {
+		config.MinLength = DefaultCompressConfig.MinLength
+	}
+	if config.SkipTypes == nil {
+		config.SkipTypes = DefaultCompressConfig.SkipTypes
+	}
+
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() any {
+			w, _ := gzip.NewWriterLevel(io.Discard, config.Level)
+			return w
+		}},
+		"deflate": {New: func() any {
+			w, _ := zlib.NewWriterLevel(io.Discard, config.Level)
+			return w
+		}},
+	}
+
+	return func(c slim.Context, next slim.HandlerFunc) error {
+		c.Vary(slim.HeaderAcceptEncoding)
+		if c.Request().Method == http.MethodHead || c.Header(slim.HeaderAcceptEncoding) == "" {
+			return next(c)
+		}
+		encoding := c.Slim().Negotiator().Encoding(c.Request(), "gzip", "deflate", "identity")
+		if encoding == "" || encoding == "identity" {
+			return next(c)
+		}
+
+		res := c.Response()
+		cw := compressWriterPool.Get().(*compressWriter)
+		*cw = compressWriter{
+			ResponseWriter: res,
+			config:         &config,
+			pool:           pools[encoding],
+			encoding:       encoding,
+			buf:            getBuffer(),
+		}
+		c.SetResponse(cw)
+		done := false
+		defer func() {
+			c.SetResponse(res)
+			if !done {
+				// the handler panicked, drop the buffered body so that the
+				// error response goes straight to the client
+				cw.discard()
+			}
+			freeBuffer(cw.buf)
+			*cw = compressWriter{}
+			compressWriterPool.Put(cw)
+		}()
+		err := next(c)
+		done = true
+
+		if cerr := cw.close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		return err
+	}
+}
+
+// compressor is implemented by both `gzip.Writer` and `zlib.Writer`, the
+// "deflate" coding of HTTP being the zlib format.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var compressWriterPool = sync.Pool{
+	New: func() any { return new(compressWriter) },
+}
+
+// compressWriter buffers the start of the response until it knows whether
+// the response is worth compressing: when MinLength bytes are written, when
+// the handler flushes or when the handler returns.
+type compressWriter struct {
+	slim.ResponseWriter
+	config    *CompressConfig
+	pool      *sync.Pool
+	encoding  string
+	buf       *[]byte
+	status    int
+	committed bool
+	// writer compresses the response, nil when the response is sent as is
+	writer compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.committed {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.committed {
+		if w.writer != nil {
+			return w.writer.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	// buffer no more than MinLength bytes, the rest is streamed once the
+	// decision is made
+	n := min(len(b), w.config.MinLength-len(*w.buf))
+	*w.buf = append(*w.buf, b[:n]...)
+	if len(*w.buf) < w.config.MinLength {
+		return n, nil
+	}
+	if err := w.commit(false); err != nil {
+		return 0, err
+	}
+	if n == len(b) {
+		return n, nil
+	}
+	m, err := w.Write(b[n:])
+	return n + m, err
+}
+
+// commit decides whether to compress, writes the status code and the
+// buffered body, and switches to pass-through mode. A streamed response is
+// compressed whatever its length so far.
+func (w *compressWriter) commit(stream bool) error {
+	w.committed = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.shouldCompress(stream) {
+		header := w.Header()
+		header.Del(slim.HeaderContentLength)
+		header.Del(slim.HeaderAcceptRanges)
+		header.Set(slim.HeaderContentEncoding, w.encoding)
+		// the compressed body is no longer byte-for-byte identical
+		if etag := header.Get(slim.HeaderETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set(slim.HeaderETag, "W/"+etag)
+		}
+		w.writer = w.pool.Get().(compressor)
+		w.writer.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(*w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.writer != nil {
+		_, err = w.writer.Write(*w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(*w.buf)
+	}
+	*w.buf = (*w.buf)[:0]
+	return err
+}
+
+func (w *compressWriter) shouldCompress(stream bool) bool {
+	switch w.status {
+	case http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+	if w.status < http.StatusOK || !stream && len(*w.buf) < w.config.MinLength {
+		return false
+	}
+	header := w.Header()
+	if header.Get(slim.HeaderContentEncoding) != "" || header.Get(slim.HeaderContentRange) != "" ||
+		strings.Contains(strings.ToLower(header.Get(slim.HeaderCacheControl)), "no-transform") {
+		return false
+	}
+	// net/http can no longer sniff the content type once the body is
+	// compressed, so sniff it here.
+	if _, ok := header[slim.HeaderContentType]; !ok && len(*w.buf) > 0 {
+		header.Set(slim.HeaderContentType, http.DetectContentType(*w.buf))
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get(slim.HeaderContentType))
+	for _, skip := range w.config.SkipTypes {
+		if prefix, ok := strings.CutSuffix(skip, "/*"); ok {
+			if typ, _, _ := strings.Cut(mediaType, "/"); strings.EqualFold(typ, prefix) {
+				return false
+			}
+		} else if strings.EqualFold(mediaType, skip) {
+			return false
+		}
+	}
+	return true
+}
+
+// close commits the response if the handler wrote anything and finishes
+// the compressed stream.
+func (w *compressWriter) close() error {
+	var err error
+	if !w.committed && w.status != 0 {
+		err = w.commit(false)
+	}
+	if w.writer != nil {
+		if cerr := w.writer.Close(); err == nil {
+			err = cerr
+		}
+		w.writer.Reset(io.Discard)
+		w.pool.Put(w.writer)
+		w.writer = nil
+	}
+	return err
+}
+
+// discard drops the buffered body, ending the compressed stream when the
+// response is already on its way.
+func (w *compressWriter) discard() {
+	*w.buf = (*w.buf)[:0]
+	if w.committed {
+		_ = w.close()
+	}
+}
+
+func (w *compressWriter) Flush() {
+	if !w.committed {
+		if err := w.commit(true); err != nil {
+			return
+		}
+	}
+	if w.writer != nil {
+		if err := w.writer.Flush(); err != nil {
+			return
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	w.committed = true
+	return hijacker.Hijack()
+}
+
+func (w *compressWriter) Status() int {
+	if w.committed {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *compressWriter) Written() bool {
+	return w.Status() != 0
+}
+
+func (w *compressWriter) Size() int {
+	if w.committed {
+		return w.ResponseWriter.Size()
+	}
+	return len(*w.buf)
+}
@@ -0,0 +1,259 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-slim.dev/slim"
+)
+
+var largeText = strings.Repeat("hello, compression! ", 200)
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	case "deflate":
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	default:
+		return string(body)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCompress_Negotiation(t *testing.T) {
+	s := slim.New()
+	s.Use(Compress())
+	s.GET("/", func(c slim.Context) error { return c.String(http.StatusOK, largeText) })
+
+	cases := []struct{ accept, want string }{
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, deflate;q=0.1", "deflate"},
+		{"*", "gzip"},
+		{"*;q=0.5, gzip;q=0", "deflate"},
+		{"br", ""},
+		{"gzip;q=0.5, identity", ""},
+		{"identity;q=0, gzip;q=0", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		hdr := map[string]string{}
+		if c.accept != "" {
+			hdr[slim.HeaderAcceptEncoding] = c.accept
+		}
+		rw := performReq(t, s, http.MethodGet, "/", hdr)
+		if got := rw.Header().Get(slim.HeaderContentEncoding); got != c.want {
+			t.Errorf("Accept-Encoding %q: got encoding %q, want %q", c.accept, got, c.want)
+			continue
+		}
+		if body := decompress(t, c.want, rw.Body.Bytes()); body != largeText {
+			t.Errorf("Accept-Encoding %q: body mismatch", c.accept)
+		}
+		if rw.Header().Get(slim.HeaderVary) != slim.HeaderAcceptEncoding {
+			t.Errorf("Accept-Encoding %q: Vary=%q", c.accept, rw.Header().Get(slim.HeaderVary))
+		}
+	}
+}
+
+func TestCompress_Skipped(t *testing.T) {
+	s := slim.New()
+	s.Use(Compress())
+	s.GET("/small", func(c slim.Context) error { return c.String(http.StatusOK, "tiny") })
+	s.GET("/png", func(c slim.Context) error { return c.Blob(http.StatusOK, "image/png", []byte(largeText)) })
+	s.GET("/encoded", func(c slim.Context) error {
+		c.SetHeader(slim.HeaderContentEncoding, "br")
+		return c.String(http.StatusOK, largeText)
+	})
+	s.GET("/no-transform", func(c slim.Context) error {
+		c.SetHeader(slim.HeaderCacheControl, "public, no-transform")
+		return c.String(http.StatusOK, largeText)
+	})
+	s.GET("/empty", func(c slim.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	for _, path := range []string{"/small", "/png", "/encoded", "/no-transform", "/empty"} {
+		rw := performReq(t, s, http.MethodGet, path, map[string]string{slim.HeaderAcceptEncoding: "gzip"})
+		if enc := rw.Header().Get(slim.HeaderContentEncoding); enc == "gzip" {
+			t.Errorf("%s: unexpected compression", path)
+		}
+	}
+	rw := performReq(t, s, http.MethodHead, "/small", map[string]string{slim.HeaderAcceptEncoding: "gzip"})
+	if rw.Header().Get(slim.HeaderContentEncoding) != "" {
+		t.Error("HEAD: unexpected compression")
+	}
+}
+
+func TestCompress_Headers(t *testing.T) {
+	s := slim.New()
+	s.Use(CompressWithConfig(CompressConfig{MinLength: 10, Level: flate.BestSpeed}))
+	s.GET("/", func(c slim.Context) error {
+		c.SetHeader(slim.HeaderETag, `"abc"`)
+		c.SetHeader(slim.HeaderContentLength, "100")
+		c.Response().WriteHeader(http.StatusCreated)
+		_, err := c.Response().Write([]byte("<html><body>sniff me</body></html>"))
+		return err
+	})
+	rw := performReq(t, s, http.MethodGet, "/", map[string]string{slim.HeaderAcceptEncoding: "gzip"})
+	h := rw.Header()
+	if rw.Code != http.StatusCreated || h.Get(slim.HeaderContentEncoding) != "gzip" || h.Get(slim.HeaderContentLength) != "" {
+		t.Fatalf("code=%d headers=%v", rw.Code, h)
+	}
+	if h.Get(slim.HeaderETag) != `W/"abc"` || !strings.HasPrefix(h.Get(slim.HeaderContentType), "text/html") {
+		t.Fatalf("headers=%v", h)
+	}
+	if body := decompress(t, "gzip", rw.Body.Bytes()); body != "<html><body>sniff me</body></html>" {
+		t.Fatalf("body=%q", body)
+	}
+}
+
+func TestCompress_ErrorBeforeWrite(t *testing.T) {
+	s := slim.New()
+	s.Use(Compress())
+	s.GET("/", func(c slim.Context) error { return slim.ErrNotFound })
+	rw := performReq(t, s, http.MethodGet, "/", map[string]string{slim.HeaderAcceptEncoding: "gzip"})
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("code=%d", rw.Code)
+	}
+}
+
+func TestCompress_Stream(t *testing.T) {
+	s := slim.New()
+	s.Use(Compress())
+	s.GET("/", func(c slim.Context) error {
+		return c.Stream(http.StatusOK, slim.MIMETextPlain, strings.NewReader(largeText))
+	})
+	rw := performReq(t, s, http.MethodGet, "/", map[string]string{slim.HeaderAcceptEncoding: "deflate"})
+	if rw.Header().Get(slim.HeaderContentEncoding) != "deflate" || decompress(t, "deflate", rw.Body.Bytes()) != largeText {
+		t.Fatalf("headers=%v", rw.Header())
+	}
+}
+
+func TestCompress_LargeWriteNotBuffered(t *testing.T) {
+	s := slim.New()
+	s.Use(CompressWithConfig(CompressConfig{MinLength: 100}))
+	s.GET("/", func(c slim.Context) error {
+		c.Response().Header().Set(slim.HeaderContentType, slim.MIMETextPlain)
+		cw := c.Response().(*compressWriter)
+		*cw.buf = nil // start from an unallocated buffer to observe its growth
+		// the first write stays below MinLength, the second crosses it
+		if _, err := cw.Write([]byte(largeText[:60])); err != nil {
+			return err
+		}
+		if len(*cw.buf) != 60 || cw.committed {
+			t.Errorf("expected 60 buffered bytes, got %d", len(*cw.buf))
+		}
+		n, err := cw.Write([]byte(largeText[60:]))
+		if err != nil {
+			return err
+		}
+		if n != len(largeText)-60 {
+			t.Errorf("expected %d bytes written, got %d", len(largeText)-60, n)
+		}
+		if !cw.committed || cap(*cw.buf) >= len(largeText) {
+			t.Errorf("expected the body to be streamed, buffer capacity %d", cap(*cw.buf))
+		}
+		return nil
+	})
+	rw := performReq(t, s, http.MethodGet, "/", map[string]string{slim.HeaderAcceptEncoding: "gzip"})
+	if rw.Header().Get(slim.HeaderContentEncoding) != "gzip" || decompress(t, "gzip", rw.Body.Bytes()) != largeText {
+		t.Fatalf("headers=%v", rw.Header())
+	}
+}
+
+func TestCompress_FlushSSE(t *testing.T) {
+	s := slim.New()
+	s.Use(Compress())
+	rw := httptest.NewRecorder()
+	var first string
+	s.GET("/events", func(c slim.Context) error {
+		c.SetHeader(slim.HeaderContentType, "text/event-stream")
+		c.Response().Write([]byte("data: one\n\n"))
+		c.Response().Flush()
+		// everything written so far must be readable by the client
+		zr, err := gzip.NewReader(bytes.NewReader(rw.Body.Bytes()))
+		if err != nil {
+			return err
+		}
+		b := make([]byte, len("data: one\n\n"))
+		if _, err = io.ReadFull(zr, b); err != nil {
+			return err
+		}
+		first = string(b)
+		c.Response().Write([]byte("data: two\n\n"))
+		c.Response().Flush()
+		return nil
+	})
+	s.ErrorHandler = func(c slim.Context, err error) { t.Fatal(err) }
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set(slim.HeaderAcceptEncoding, "gzip")
+	s.ServeHTTP(rw, req)
+	if first != "data: one\n\n" || !rw.Flushed {
+		t.Fatalf("first=%q flushed=%v", first, rw.Flushed)
+	}
+	if body := decompress(t, "gzip", rw.Body.Bytes()); body != "data: one\n\ndata: two\n\n" {
+		t.Fatalf("body=%q", body)
+	}
+}
+
+func TestCompress_HijackAndPush(t *testing.T) {
+	s := slim.New()
+	s.Use(Compress())
+	s.GET("/", func(c slim.Context) error {
+		if _, _, err := c.Response().(http.Hijacker).Hijack(); err == nil {
+			return errors.New("expected hijack error from recorder")
+		}
+		if err := c.Response().Push("/app.js", nil); err == nil {
+			return errors.New("expected push error from recorder")
+		}
+		return nil
+	})
+	s.ErrorHandler = func(c slim.Context, err error) { t.Fatal(err) }
+	performReq(t, s, http.MethodGet, "/", map[string]string{slim.HeaderAcceptEncoding: "gzip"})
+}
+
+func TestCompress_InvalidLevel(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	CompressWithConfig(CompressConfig{Level: 42})
+}
+
+func TestCompress_Panic(t *testing.T) {
+	s := slim.New()
+	s.Use(Recovery(), Compress())
+	s.GET("/", func(c slim.Context) error {
+		_ = c.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+	rw := performReq(t, s, http.MethodGet, "/", map[string]string{slim.HeaderAcceptEncoding: "gzip"})
+	if rw.Code != http.StatusInternalServerError || rw.Header().Get(slim.HeaderContentEncoding) != "" ||
+		strings.Contains(rw.Body.String(), "partial") {
+		t.Fatalf("code=%d headers=%v body=%q", rw.Code, rw.Header(), rw.Body.String())
+	}
+}
@@ -90,7 +90,7 @@ func CORSWithConfig(config CORSConfig) slim.MiddlewareFunc {
 		allowOrigin := ""
 
 		preflight := req.Method == http.MethodOptions
-		res.Header().Add(slim.HeaderVary, slim.HeaderOrigin)
+		c.Vary(slim.HeaderOrigin)
 
 		// No Origin provided
 		if origin == "" {
@@ -167,8 +167,7 @@ func CORSWithConfig(config CORSConfig) slim.MiddlewareFunc {
 		}
 
 		// Preflight request
-		res.Header().Add(slim.HeaderVary, slim.HeaderAccessControlRequestMethod)
-		res.Header().Add(slim.HeaderVary, slim.HeaderAccessControlRequestHeaders)
+		c.Vary(slim.HeaderAccessControlRequestMethod, slim.HeaderAccessControlRequestHeaders)
 		res.Header().Set(slim.HeaderAccessControlAllowOrigin, allowOrigin)
 		res.Header().Set(slim.HeaderAccessControlAllowMethods, allowMethods)
 		if config.AllowCredentials {
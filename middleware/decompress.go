@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go-slim.dev/slim"
+)
+
+// DecompressConfig defines the config for Decompress middleware.
+type DecompressConfig struct {
+	// MaxSize is the largest decompressed request body, in bytes, guarding
+	// against decompression bombs. Reading past it fails with an
+	// `http.MaxBytesError`, which `slim.BindBody` reports as 413.
+	// Optional. Default value 10MB.
+	MaxSize int64
+}
+
+// DefaultDecompressConfig is the default Decompress middleware config.
+var DefaultDecompressConfig = DecompressConfig{
+	MaxSize: 10 << 20, // 10 MB
+}
+
+// Decompress returns a middleware which transparently decodes request bodies
+// sent with a gzip or deflate `Content-Encoding`, so that `c.Bind` and
+// `slim.BindBody` read the original content.
+//
+// Requests with any other encoding are answered with 415 Unsupported Media
+// Type and an `Accept-Encoding` header listing the supported encodings.
+func Decompress() slim.MiddlewareFunc {
+	return DecompressWithConfig(DefaultDecompressConfig)
+}
+
+// DecompressWithConfig returns a Decompress middleware with config.
+// See: `Decompress()`.
+func DecompressWithConfig(config DecompressConfig) slim.MiddlewareFunc {
+	return config.ToMiddleware()
+}
+
+// ToMiddleware converts DecompressConfig to middleware.
+func (config DecompressConfig) ToMiddleware() slim.MiddlewareFunc {
+	if config.MaxSize <= 0 {
+		config.MaxSize = DefaultDecompressConfig.MaxSize
+	}
+	return func(c slim.Context, next slim.HandlerFunc) error {
+		req := c.Request()
+		encoding := strings.ToLower(strings.TrimSpace(req.Header.Get(slim.HeaderContentEncoding)))
+		if req.ContentLength == 0 {
+			return next(c)
+		}
+		switch encoding {
+		case "", "identity":
+			return next(c)
+		case "gzip", "x-gzip", "deflate":
+		default:
+			c.SetHeader(slim.HeaderAcceptEncoding, "gzip, deflate")
+			return slim.NewHTTPError(http.StatusUnsupportedMediaType, "Unsupported Content-Encoding")
+		}
+
+		body := &decompressBody{body: req.Body, encoding: encoding}
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{http.MaxBytesReader(c.Response(), body, config.MaxSize), body}
+		req.ContentLength = -1
+		req.Header.Del(slim.HeaderContentEncoding)
+		req.Header.Del(slim.HeaderContentLength)
+		defer body.release()
+		return next(c)
+	}
+}
+
+var gzipReaderPool sync.Pool
+
+// decompressBody decodes the request body on first read, so that handlers
+// which never read the body do not pay for it.
+type decompressBody struct {
+	body     io.ReadCloser
+	encoding string
+	reader   io.Reader
+	gzip     *gzip.Reader
+	err      error
+}
+
+func (b *decompressBody) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		b.reader, b.err = b.open()
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.reader.Read(p)
+}
+
+func (b *decompressBody) open() (io.Reader, error) {
+	if b.encoding != "deflate" {
+		zr, _ := gzipReaderPool.Get().(*gzip.Reader)
+		if zr == nil {
+			var err error
+			if zr, err = gzip.NewReader(b.body); err != nil {
+				return nil, err
+			}
+		} else if err := zr.Reset(b.body); err != nil {
+			gzipReaderPool.Put(zr)
+			return nil, err
+		}
+		b.gzip = zr
+		return zr, nil
+	}
+	// The deflate coding is the zlib format, but some clients send raw
+	// deflate data, which the zlib header check tells apart.
+	var header [2]byte
+	n, err := io.ReadFull(b.body, header[:])
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	r := io.MultiReader(strings.NewReader(string(header[:n])), b.body)
+	if n == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(r)
+	}
+	return flate.NewReader(r), nil
+}
+
+func (b *decompressBody) Close() error {
+	return b.body.Close()
+}
+
+// release returns the gzip reader to the pool once the handler is done.
+func (b *decompressBody) release() {
+	if b.gzip != nil {
+		gzipReaderPool.Put(b.gzip)
+		b.gzip = nil
+	}
+	b.reader = nil
+	b.err = http.ErrBodyReadAfterClose
+}
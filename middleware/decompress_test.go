@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-slim.dev/slim"
+)
+
+func compressBody(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.BestSpeed)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newDecompressApp(config DecompressConfig) *slim.Slim {
+	s := slim.New()
+	s.ErrorHandler = func(c slim.Context, err error) {
+		var he *slim.HTTPError
+		if errors.As(err, &he) {
+			_ = c.String(he.Code, err.Error())
+			return
+		}
+		_ = c.String(http.StatusInternalServerError, err.Error())
+	}
+	s.Use(DecompressWithConfig(config))
+	s.POST("/", func(c slim.Context) error {
+		var v struct {
+			Name string `json:"name"`
+		}
+		if err := c.Bind(&v); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, v.Name)
+	})
+	return s
+}
+
+func postBody(s *slim.Slim, encoding string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set(slim.HeaderContentType, slim.MIMEApplicationJSON)
+	if encoding != "" {
+		req.Header.Set(slim.HeaderContentEncoding, encoding)
+	}
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, req)
+	return rw
+}
+
+func TestDecompress_Encodings(t *testing.T) {
+	s := newDecompressApp(DecompressConfig{})
+	payload := []byte(`{"name":"slim"}`)
+	cases := []struct{ header, format string }{
+		{"gzip", "gzip"},
+		{"X-Gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate", "raw-deflate"},
+	}
+	for _, c := range cases {
+		rw := postBody(s, c.header, compressBody(t, c.format, payload))
+		if rw.Code != http.StatusOK || rw.Body.String() != "slim" {
+			t.Errorf("%s (%s): code=%d body=%q", c.header, c.format, rw.Code, rw.Body.String())
+		}
+	}
+	if rw := postBody(s, "", payload); rw.Code != http.StatusOK || rw.Body.String() != "slim" {
+		t.Errorf("identity: code=%d body=%q", rw.Code, rw.Body.String())
+	}
+}
+
+func TestDecompress_Errors(t *testing.T) {
+	s := newDecompressApp(DecompressConfig{MaxSize: 1024})
+
+	rw := postBody(s, "br", []byte("whatever"))
+	if rw.Code != http.StatusUnsupportedMediaType || rw.Header().Get(slim.HeaderAcceptEncoding) != "gzip, deflate" {
+		t.Fatalf("unsupported: code=%d headers=%v", rw.Code, rw.Header())
+	}
+
+	bomb := compressBody(t, "gzip", []byte(`{"name":"`+strings.Repeat("a", 1<<20)+`"}`))
+	if len(bomb) > 4096 {
+		t.Fatalf("bomb is not compressed enough: %d", len(bomb))
+	}
+	if rw = postBody(s, "gzip", bomb); rw.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("bomb: code=%d body=%q", rw.Code, rw.Body.String())
+	}
+
+	if rw = postBody(s, "gzip", []byte("not gzip")); rw.Code != http.StatusBadRequest {
+		t.Fatalf("corrupt: code=%d body=%q", rw.Code, rw.Body.String())
+	}
+}
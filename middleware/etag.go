@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"go-slim.dev/slim"
+)
+
+// ETagConfig defines the config for ETag middleware.
+type ETagConfig struct {
+	// Weak generates weak entity tags (W/"...") instead of strong ones.
+	// Use it when the response may be transformed downstream, for
+	// example by a compression middleware.
+	// Optional. Default value is false.
+	Weak bool
+	// MaxBodySize is the largest response body that is buffered to compute
+	// an entity tag. Larger responses are streamed through unchanged.
+	// Optional. Default value 1MB.
+	MaxBodySize int
+}
+
+// DefaultETagConfig is the default ETag middleware config.
+var DefaultETagConfig = ETagConfig{
+	Weak:        false,
+	MaxBodySize: 1 << 20, // 1 MB
+}
+
+// ETag returns a middleware which buffers successful GET and HEAD responses,
+// tags them with an entity tag derived from the body and answers conditional
+// requests (`If-None-Match`, `If-Match`...) with 304 or 412.
+//
+// Handlers that already set an `ETag` header keep it, and responses that
+// are flushed explicitly, such as `c.Stream` used for SSE, are not buffered.
+func ETag() slim.MiddlewareFunc {
+	return ETagWithConfig(DefaultETagConfig)
+}
+
+// ETagWithConfig returns an ETag middleware with config.
+// See: `ETag()`.
+func ETagWithConfig(config ETagConfig) slim.MiddlewareFunc {
+	return config.ToMiddleware()
+}
+
+// ToMiddleware converts ETagConfig to middleware.
+func (config ETagConfig) ToMiddleware() slim.MiddlewareFunc {
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultETagConfig.MaxBodySize
+	}
+	return func(c slim.Context, next slim.HandlerFunc) error {
+		method := c.Request().Method
+		if method != http.MethodGet && method != http.MethodHead {
+			return next(c)
+		}
+
+		res := c.Response()
+		buf := getBuffer()
+		defer freeBuffer(buf)
+		ew := &etagWriter{ResponseWriter: res, buf: buf, limit: config.MaxBodySize}
+		c.SetResponse(ew)
+		err := func() error {
+			// restored even when the handler panics, the buffered body is
+			// then dropped and the error response goes straight to the client
+			defer c.SetResponse(res)
+			return next(c)
+		}()
+
+		if ew.passthrough || ew.status == 0 {
+			return err
+		}
+		if err == nil && ew.status == http.StatusOK {
+			header := res.Header()
+			etag := header.Get(slim.HeaderETag)
+			if etag == "" {
+				etag = slim.GenerateETag(*buf, config.Weak)
+			}
+			modtime, _ := http.ParseTime(header.Get(slim.HeaderLastModified))
+			if done, perr := c.CheckPreconditions(etag, modtime); done {
+				return perr
+			}
+		}
+		if ferr := ew.commit(); ferr != nil && err == nil {
+			err = ferr
+		}
+		return err
+	}
+}
+
+// etagWriter buffers the response until the handler returns, or until the
+// handler asks for the response to be flushed.
+type etagWriter struct {
+	slim.ResponseWriter
+	buf         *[]byte
+	limit       int
+	status      int
+	passthrough bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if len(*w.buf)+len(b) > w.limit {
+		if err := w.commit(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	*w.buf = append(*w.buf, b...)
+	return len(b), nil
+}
+
+// commit writes the buffered response and switches to pass-through mode.
+func (w *etagWriter) commit() error {
+	if w.passthrough {
+		return nil
+	}
+	w.passthrough = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(*w.buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(*w.buf)
+	*w.buf = (*w.buf)[:0]
+	return err
+}
+
+func (w *etagWriter) Flush() {
+	if err := w.commit(); err == nil {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	w.passthrough = true
+	return hijacker.Hijack()
+}
+
+func (w *etagWriter) Status() int {
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *etagWriter) Written() bool {
+	return w.Status() != 0
+}
+
+func (w *etagWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	return len(*w.buf)
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-slim.dev/slim"
+)
+
+func TestETag_GeneratesAndAnswers304(t *testing.T) {
+	s := slim.New()
+	s.Use(ETag())
+	s.GET("/", func(c slim.Context) error { return c.JSON(http.StatusOK, slim.Map{"a": 1}) })
+
+	rw := performReq(t, s, http.MethodGet, "/", nil)
+	etag := rw.Header().Get(slim.HeaderETag)
+	if rw.Code != http.StatusOK || etag == "" || !strings.Contains(rw.Body.String(), `"a"`) {
+		t.Fatalf("code=%d etag=%q body=%q", rw.Code, etag, rw.Body.String())
+	}
+
+	rw = performReq(t, s, http.MethodGet, "/", map[string]string{slim.HeaderIfNoneMatch: etag})
+	if rw.Code != http.StatusNotModified || rw.Body.Len() != 0 {
+		t.Fatalf("code=%d body=%q", rw.Code, rw.Body.String())
+	}
+	if rw.Header().Get(slim.HeaderContentType) != "" {
+		t.Fatalf("304 must not carry Content-Type")
+	}
+}
+
+func TestETag_WeakAndSkipped(t *testing.T) {
+	s := slim.New()
+	s.Use(ETagWithConfig(ETagConfig{Weak: true}))
+	s.GET("/", func(c slim.Context) error { return c.String(http.StatusOK, "ok") })
+	s.GET("/created", func(c slim.Context) error { return c.String(http.StatusCreated, "new") })
+	s.POST("/", func(c slim.Context) error { return c.String(http.StatusOK, "ok") })
+
+	if rw := performReq(t, s, http.MethodGet, "/", nil); !strings.HasPrefix(rw.Header().Get(slim.HeaderETag), `W/"`) {
+		t.Fatalf("expected weak etag, got %q", rw.Header().Get(slim.HeaderETag))
+	}
+	if rw := performReq(t, s, http.MethodGet, "/created", nil); rw.Header().Get(slim.HeaderETag) != "" || rw.Body.String() != "new" {
+		t.Fatalf("non-200 responses must not be tagged")
+	}
+	if rw := performReq(t, s, http.MethodPost, "/", nil); rw.Header().Get(slim.HeaderETag) != "" {
+		t.Fatalf("POST responses must not be tagged")
+	}
+}
+
+func TestETag_FlushStreamsThrough(t *testing.T) {
+	s := slim.New()
+	s.Use(ETag())
+	s.GET("/", func(c slim.Context) error {
+		c.Response().Write([]byte("a"))
+		c.Response().Flush()
+		c.Response().Write([]byte("b"))
+		return nil
+	})
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rw.Body.String() != "ab" || rw.Header().Get(slim.HeaderETag) != "" || !rw.Flushed {
+		t.Fatalf("body=%q etag=%q flushed=%v", rw.Body.String(), rw.Header().Get(slim.HeaderETag), rw.Flushed)
+	}
+}
+
+func TestETag_Panic(t *testing.T) {
+	s := slim.New()
+	s.Use(Recovery(), ETag())
+	s.GET("/", func(c slim.Context) error {
+		_ = c.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+	rw := performReq(t, s, http.MethodGet, "/", nil)
+	if rw.Code != http.StatusInternalServerError || strings.Contains(rw.Body.String(), "partial") {
+		t.Fatalf("code=%d body=%q", rw.Code, rw.Body.String())
+	}
+}
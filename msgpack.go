@@ -0,0 +1,928 @@
+package slim
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// MsgpackCodec implements Codec for MessagePack, see
+// https://github.com/msgpack/msgpack/blob/master/spec.md.
+//
+// Struct fields are named by their `msgpack` tag, then by their `json` tag,
+// then by the field name; both tags support "-" and the "omitempty" option.
+// `time.Time` is encoded with the timestamp extension type (-1), other
+// extension types are represented by MsgpackExt.
+//
+// Decoding into an `any` value yields nil, bool, int64 (uint64 for values
+// larger than `math.MaxInt64`), float32, float64, string, []byte, []any,
+// map[string]any (map[any]any when a key is not a string), time.Time or
+// MsgpackExt.
+type MsgpackCodec struct{}
+
+// MsgpackExt is a MessagePack extension value.
+type MsgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// msgpackMaxDepth limits the nesting of encoded and decoded values, it
+// protects against cyclic values and deeply nested input.
+const msgpackMaxDepth = 1000
+
+// Encode writes v as a single MessagePack value, indent is ignored.
+func (MsgpackCodec) Encode(w io.Writer, v any, _ string) error {
+	e := msgpackEncoder{buf: make([]byte, 0, 256)}
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return err
+	}
+	_, err := w.Write(e.buf)
+	return err
+}
+
+// Decode reads a single MessagePack value from r into v, which must be a
+// non-nil pointer.
+func (MsgpackCodec) Decode(r io.Reader, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("msgpack: decode into non-pointer or nil %T", v)
+	}
+	br, ok := r.(codecReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	d := msgpackDecoder{r: br}
+	return d.decode(rv.Elem(), 0)
+}
+
+var (
+	msgpackExtType  = reflect.TypeOf(MsgpackExt{})
+	errMsgpackDepth = errors.New("msgpack: maximum nesting depth exceeded")
+)
+
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value, depth int) error {
+	if depth > msgpackMaxDepth {
+		return errMsgpackDepth
+	}
+	if !v.IsValid() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+	switch v.Type() {
+	case timeType:
+		e.encodeTime(v.Interface().(time.Time))
+		return nil
+	case msgpackExtType:
+		ext := v.Interface().(MsgpackExt)
+		return e.encodeExt(ext.Type, ext.Data)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, 0xca)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		return e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return e.encodeBin(v.Bytes())
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return e.encodeBin(b)
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encodeMap(v, depth)
+	case reflect.Struct:
+		return e.encodeStruct(v, depth)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encode(v.Elem(), depth+1)
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeInt(i int64) {
+	switch {
+	case i >= 0:
+		e.encodeUint(uint64(i))
+	case i >= -32:
+		e.buf = append(e.buf, byte(i))
+	case i >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(i))
+	case i >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(i))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(i))
+	}
+}
+
+func (e *msgpackEncoder) encodeUint(u uint64) {
+	switch {
+	case u <= math.MaxInt8:
+		e.buf = append(e.buf, byte(u))
+	case u <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(u))
+	case u <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(u))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, u)
+	}
+}
+
+// encodeLength writes the header of a value whose format depends on its
+// length: fix is the fixed format (or 0 if there is none) holding lengths up
+// to fixMax, formats are the 8, 16 and 32 bit formats (0 if there is none).
+func (e *msgpackEncoder) encodeLength(n int, fix byte, fixMax int, formats [3]byte) error {
+	switch {
+	case fix != 0 && n <= fixMax:
+		e.buf = append(e.buf, fix|byte(n))
+	case formats[0] != 0 && n <= math.MaxUint8:
+		e.buf = append(e.buf, formats[0], byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, formats[1])
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case uint64(n) <= math.MaxUint32:
+		e.buf = append(e.buf, formats[2])
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		return fmt.Errorf("msgpack: length %d exceeds the format limit", n)
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeString(s string) error {
+	if err := e.encodeLength(len(s), 0xa0, 31, [3]byte{0xd9, 0xda, 0xdb}); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, s...)
+	return nil
+}
+
+func (e *msgpackEncoder) encodeBin(b []byte) error {
+	if err := e.encodeLength(len(b), 0, 0, [3]byte{0xc4, 0xc5, 0xc6}); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, b...)
+	return nil
+}
+
+func (e *msgpackEncoder) encodeArray(v reflect.Value, depth int) error {
+	if err := e.encodeLength(v.Len(), 0x90, 15, [3]byte{0, 0xdc, 0xdd}); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeMap(v reflect.Value, depth int) error {
+	if err := e.encodeLength(v.Len(), 0x80, 15, [3]byte{0, 0xde, 0xdf}); err != nil {
+		return err
+	}
+	keys := v.MapKeys()
+	if v.Type().Key().Kind() == reflect.String {
+		// sorted keys make the output deterministic, like encoding/json
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	}
+	for _, k := range keys {
+		if err := e.encode(k, depth+1); err != nil {
+			return err
+		}
+		if err := e.encode(v.MapIndex(k), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeStruct(v reflect.Value, depth int) error {
+	fields := codecFields(v.Type(), "msgpack")
+	values := make([]reflect.Value, len(fields))
+	n := 0
+	for i, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		values[i] = fv
+		n++
+	}
+	if err := e.encodeLength(n, 0x80, 15, [3]byte{0, 0xde, 0xdf}); err != nil {
+		return err
+	}
+	for i, f := range fields {
+		if !values[i].IsValid() {
+			continue
+		}
+		if err := e.encodeString(f.name); err != nil {
+			return err
+		}
+		if err := e.encode(values[i], depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeExt(typ int8, data []byte) error {
+	switch len(data) {
+	case 1:
+		e.buf = append(e.buf, 0xd4)
+	case 2:
+		e.buf = append(e.buf, 0xd5)
+	case 4:
+		e.buf = append(e.buf, 0xd6)
+	case 8:
+		e.buf = append(e.buf, 0xd7)
+	case 16:
+		e.buf = append(e.buf, 0xd8)
+	default:
+		if err := e.encodeLength(len(data), 0, 0, [3]byte{0xc7, 0xc8, 0xc9}); err != nil {
+			return err
+		}
+	}
+	e.buf = append(e.buf, byte(typ))
+	e.buf = append(e.buf, data...)
+	return nil
+}
+
+// encodeTime writes the timestamp extension in the smallest of its 32, 64
+// and 96 bit formats.
+func (e *msgpackEncoder) encodeTime(t time.Time) {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	if uint64(sec)>>34 == 0 {
+		data := nsec<<34 | uint64(sec)
+		if data>>32 == 0 {
+			e.buf = append(e.buf, 0xd6, 0xff)
+			e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(data))
+			return
+		}
+		e.buf = append(e.buf, 0xd7, 0xff)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, data)
+		return
+	}
+	e.buf = append(e.buf, 0xc7, 12, 0xff)
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(nsec))
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(sec))
+}
+
+type msgpackDecoder struct {
+	r       codecReader
+	scratch [8]byte
+}
+
+// family of a MessagePack format
+const (
+	msgpackNil = iota
+	msgpackBool
+	msgpackInt
+	msgpackFloat
+	msgpackStr
+	msgpackBin
+	msgpackArray
+	msgpackMap
+	msgpackExt
+	msgpackInvalid
+)
+
+var msgpackFamilyNames = [...]string{"nil", "bool", "integer", "float", "string", "binary", "array", "map", "extension", "invalid"}
+
+func msgpackFamily(c byte) int {
+	switch {
+	case c <= 0x7f || c >= 0xe0:
+		return msgpackInt
+	case c <= 0x8f:
+		return msgpackMap
+	case c <= 0x9f:
+		return msgpackArray
+	case c <= 0xbf:
+		return msgpackStr
+	}
+	switch c {
+	case 0xc0:
+		return msgpackNil
+	case 0xc2, 0xc3:
+		return msgpackBool
+	case 0xc4, 0xc5, 0xc6:
+		return msgpackBin
+	case 0xc7, 0xc8, 0xc9, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return msgpackExt
+	case 0xca, 0xcb:
+		return msgpackFloat
+	case 0xcc, 0xcd, 0xce, 0xcf, 0xd0, 0xd1, 0xd2, 0xd3:
+		return msgpackInt
+	case 0xd9, 0xda, 0xdb:
+		return msgpackStr
+	case 0xdc, 0xdd:
+		return msgpackArray
+	case 0xde, 0xdf:
+		return msgpackMap
+	}
+	return msgpackInvalid
+}
+
+func (d *msgpackDecoder) readByte() (byte, error) {
+	c, err := d.r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return c, err
+}
+
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	b := d.scratch[:size]
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// readBytes reads n bytes. Large lengths are read incrementally, so a
+// forged length does not allocate more memory than the input holds.
+func (d *msgpackDecoder) readBytes(n int) ([]byte, error) {
+	if n <= 64<<10 {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(d.r, b); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return b, nil
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readLength reads the length of a string, binary, array or map value.
+func (d *msgpackDecoder) readLength(c byte) (int, error) {
+	var size int
+	switch c {
+	case 0xc4, 0xd9:
+		size = 1
+	case 0xc5, 0xda, 0xdc, 0xde:
+		size = 2
+	case 0xc6, 0xdb, 0xdd, 0xdf:
+		size = 4
+	default:
+		switch {
+		case c >= 0x80 && c <= 0x8f, c >= 0x90 && c <= 0x9f:
+			return int(c & 0x0f), nil
+		default: // fixstr
+			return int(c & 0x1f), nil
+		}
+	}
+	n, err := d.readUint(size)
+	if err == nil && n > math.MaxInt32 {
+		return 0, fmt.Errorf("msgpack: length %d is too large", n)
+	}
+	return int(n), err
+}
+
+// readExtHeader reads the type and length of an extension value.
+func (d *msgpackDecoder) readExtHeader(c byte) (int8, int, error) {
+	var n int
+	switch c {
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		n = 1 << (c - 0xd4)
+	default:
+		size := 1 << (c - 0xc7)
+		l, err := d.readUint(size)
+		if err != nil {
+			return 0, 0, err
+		}
+		if l > math.MaxInt32 {
+			return 0, 0, fmt.Errorf("msgpack: length %d is too large", l)
+		}
+		n = int(l)
+	}
+	typ, err := d.readByte()
+	return int8(typ), n, err
+}
+
+// readInt reads an integer, u holds the value when signed is false.
+func (d *msgpackDecoder) readInt(c byte) (i int64, u uint64, signed bool, err error) {
+	switch {
+	case c <= 0x7f:
+		return 0, uint64(c), false, nil
+	case c >= 0xe0:
+		return int64(int8(c)), 0, true, nil
+	}
+	switch c {
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err = d.readUint(1 << (c - 0xcc))
+		return 0, u, false, err
+	}
+	u, err = d.readUint(1 << (c - 0xd0))
+	switch c {
+	case 0xd0:
+		i = int64(int8(u))
+	case 0xd1:
+		i = int64(int16(u))
+	case 0xd2:
+		i = int64(int32(u))
+	default:
+		i = int64(u)
+	}
+	return i, 0, true, err
+}
+
+func (d *msgpackDecoder) readFloat(c byte) (float64, error) {
+	if c == 0xca {
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	}
+	u, err := d.readUint(8)
+	return math.Float64frombits(u), err
+}
+
+func (d *msgpackDecoder) decodeTime(n int) (time.Time, error) {
+	var sec int64
+	var nsec uint64
+	switch n {
+	case 4:
+		u, err := d.readUint(4)
+		if err != nil {
+			return time.Time{}, err
+		}
+		sec = int64(u)
+	case 8:
+		u, err := d.readUint(8)
+		if err != nil {
+			return time.Time{}, err
+		}
+		nsec, sec = u>>34, int64(u&(1<<34-1))
+	case 12:
+		u, err := d.readUint(4)
+		if err != nil {
+			return time.Time{}, err
+		}
+		nsec = u
+		if u, err = d.readUint(8); err != nil {
+			return time.Time{}, err
+		}
+		sec = int64(u)
+	default:
+		return time.Time{}, fmt.Errorf("msgpack: invalid timestamp length %d", n)
+	}
+	if nsec >= 1e9 {
+		return time.Time{}, fmt.Errorf("msgpack: invalid timestamp nanoseconds %d", nsec)
+	}
+	return time.Unix(sec, int64(nsec)).UTC(), nil
+}
+
+func (d *msgpackDecoder) decode(v reflect.Value, depth int) error {
+	if depth > msgpackMaxDepth {
+		return errMsgpackDepth
+	}
+	c, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	return d.decodeValue(c, v, depth)
+}
+
+func (d *msgpackDecoder) typeError(c byte, t reflect.Type) error {
+	return fmt.Errorf("msgpack: cannot decode %s into %s", msgpackFamilyNames[msgpackFamily(c)], t)
+}
+
+func (d *msgpackDecoder) decodeValue(c byte, v reflect.Value, depth int) error {
+	family := msgpackFamily(c)
+	if family == msgpackInvalid {
+		return fmt.Errorf("msgpack: invalid format 0x%02x", c)
+	}
+	if family == msgpackNil {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeValue(c, v.Elem(), depth+1)
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			x, err := d.decodeAny(c, depth)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(&x).Elem())
+			return nil
+		}
+		if !v.IsNil() && v.Elem().Kind() == reflect.Ptr {
+			return d.decodeValue(c, v.Elem(), depth+1)
+		}
+		return d.typeError(c, v.Type())
+	}
+
+	switch v.Type() {
+	case timeType:
+		if family != msgpackExt {
+			return d.typeError(c, v.Type())
+		}
+		typ, n, err := d.readExtHeader(c)
+		if err != nil {
+			return err
+		}
+		if typ != -1 {
+			return fmt.Errorf("msgpack: cannot decode extension type %d into time.Time", typ)
+		}
+		t, err := d.decodeTime(n)
+		if err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return err
+	case msgpackExtType:
+		if family != msgpackExt {
+			return d.typeError(c, v.Type())
+		}
+		typ, n, err := d.readExtHeader(c)
+		if err != nil {
+			return err
+		}
+		data, err := d.readBytes(n)
+		if err == nil {
+			v.Set(reflect.ValueOf(MsgpackExt{Type: typ, Data: data}))
+		}
+		return err
+	}
+
+	switch family {
+	case msgpackBool:
+		if v.Kind() != reflect.Bool {
+			return d.typeError(c, v.Type())
+		}
+		v.SetBool(c == 0xc3)
+	case msgpackInt:
+		i, u, signed, err := d.readInt(c)
+		if err != nil {
+			return err
+		}
+		return setCodecInt("msgpack", v, i, u, signed)
+	case msgpackFloat:
+		f, err := d.readFloat(c)
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(f)
+		default:
+			return d.typeError(c, v.Type())
+		}
+	case msgpackStr, msgpackBin:
+		n, err := d.readLength(c)
+		if err != nil {
+			return err
+		}
+		switch {
+		case v.Kind() == reflect.String:
+			b, err := d.readBytes(n)
+			if err != nil {
+				return err
+			}
+			v.SetString(string(b))
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			b, err := d.readBytes(n)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(b)
+		case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+			b, err := d.readBytes(n)
+			if err != nil {
+				return err
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+		default:
+			return d.typeError(c, v.Type())
+		}
+	case msgpackArray:
+		n, err := d.readLength(c)
+		if err != nil {
+			return err
+		}
+		return d.decodeArray(n, v, depth)
+	case msgpackMap:
+		n, err := d.readLength(c)
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Map:
+			return d.decodeMap(n, v, depth)
+		case reflect.Struct:
+			return d.decodeStruct(n, v, depth)
+		}
+		return d.typeError(c, v.Type())
+	case msgpackExt:
+		return d.typeError(c, v.Type())
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) decodeArray(n int, v reflect.Value, depth int) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		// grow as elements arrive, a forged length must not allocate up front
+		slice := reflect.MakeSlice(v.Type(), 0, min(n, 1024))
+		elem := reflect.New(v.Type().Elem()).Elem()
+		for i := 0; i < n; i++ {
+			elem.Set(reflect.Zero(elem.Type()))
+			if err := d.decode(elem, depth+1); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		v.Set(slice)
+	case reflect.Array:
+		for i := 0; i < n; i++ {
+			if i < v.Len() {
+				if err := d.decode(v.Index(i), depth+1); err != nil {
+					return err
+				}
+			} else if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+		}
+		for i := n; i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot decode array into %s", v.Type())
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int, v reflect.Value, depth int) error {
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, min(n, 1024)))
+	}
+	for i := 0; i < n; i++ {
+		key := reflect.New(t.Key()).Elem()
+		if err := d.decode(key, depth+1); err != nil {
+			return err
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if err := d.decode(elem, depth+1); err != nil {
+			return err
+		}
+		if !key.Comparable() {
+			return fmt.Errorf("msgpack: unhashable map key of type %s", key.Type())
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) decodeStruct(n int, v reflect.Value, depth int) error {
+	fields := codecFields(v.Type(), "msgpack")
+	for i := 0; i < n; i++ {
+		var name string
+		if err := d.decode(reflect.ValueOf(&name).Elem(), depth+1); err != nil {
+			return err
+		}
+		field := lookupCodecField(fields, name)
+		fv, ok := reflect.Value{}, false
+		if field != nil {
+			fv, ok = settableFieldByIndex(v, field.index)
+		}
+		if !ok {
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(fv, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) decodeAny(c byte, depth int) (any, error) {
+	switch msgpackFamily(c) {
+	case msgpackNil:
+		return nil, nil
+	case msgpackBool:
+		return c == 0xc3, nil
+	case msgpackInt:
+		i, u, signed, err := d.readInt(c)
+		if signed || u <= math.MaxInt64 {
+			if !signed {
+				i = int64(u)
+			}
+			return i, err
+		}
+		return u, err
+	case msgpackFloat:
+		f, err := d.readFloat(c)
+		if c == 0xca {
+			return float32(f), err
+		}
+		return f, err
+	case msgpackStr, msgpackBin:
+		n, err := d.readLength(c)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.readBytes(n)
+		if err != nil {
+			return nil, err
+		}
+		if msgpackFamily(c) == msgpackStr {
+			return string(b), nil
+		}
+		return b, nil
+	case msgpackArray:
+		n, err := d.readLength(c)
+		if err != nil {
+			return nil, err
+		}
+		var a []any
+		err = d.decodeArray(n, reflect.ValueOf(&a).Elem(), depth)
+		return a, err
+	case msgpackMap:
+		n, err := d.readLength(c)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeAnyMap(n, depth)
+	case msgpackExt:
+		typ, n, err := d.readExtHeader(c)
+		if err != nil {
+			return nil, err
+		}
+		if typ == -1 {
+			return d.decodeTime(n)
+		}
+		data, err := d.readBytes(n)
+		return MsgpackExt{Type: typ, Data: data}, err
+	}
+	return nil, fmt.Errorf("msgpack: invalid format 0x%02x", c)
+}
+
+// decodeAnyMap decodes a map into map[string]any, or into map[any]any when a
+// key is not a string.
+func (d *msgpackDecoder) decodeAnyMap(n int, depth int) (any, error) {
+	m := make(map[string]any, min(n, 1024))
+	var generic map[any]any
+	for i := 0; i < n; i++ {
+		var key, val any
+		if err := d.decode(reflect.ValueOf(&key).Elem(), depth+1); err != nil {
+			return nil, err
+		}
+		if err := d.decode(reflect.ValueOf(&val).Elem(), depth+1); err != nil {
+			return nil, err
+		}
+		if s, ok := key.(string); ok && generic == nil {
+			m[s] = val
+			continue
+		}
+		if generic == nil {
+			generic = make(map[any]any, len(m)+1)
+			for k, v := range m {
+				generic[k] = v
+			}
+		}
+		if key != nil && !reflect.TypeOf(key).Comparable() {
+			return nil, fmt.Errorf("msgpack: unhashable map key of type %T", key)
+		}
+		generic[key] = val
+	}
+	if generic != nil {
+		return generic, nil
+	}
+	return m, nil
+}
+
+// skip reads and discards the next value.
+func (d *msgpackDecoder) skip(depth int) error {
+	if depth > msgpackMaxDepth {
+		return errMsgpackDepth
+	}
+	c, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	var n, items int
+	switch msgpackFamily(c) {
+	case msgpackNil, msgpackBool:
+		return nil
+	case msgpackInt:
+		_, _, _, err = d.readInt(c)
+		return err
+	case msgpackFloat:
+		_, err = d.readFloat(c)
+		return err
+	case msgpackStr, msgpackBin:
+		if n, err = d.readLength(c); err != nil {
+			return err
+		}
+	case msgpackExt:
+		if _, n, err = d.readExtHeader(c); err != nil {
+			return err
+		}
+	case msgpackArray:
+		if items, err = d.readLength(c); err != nil {
+			return err
+		}
+	case msgpackMap:
+		if items, err = d.readLength(c); err != nil {
+			return err
+		}
+		items *= 2
+	default:
+		return fmt.Errorf("msgpack: invalid format 0x%02x", c)
+	}
+	if n > 0 {
+		if _, err = io.CopyN(io.Discard, d.r, int64(n)); err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	for i := 0; i < items; i++ {
+		if err = d.skip(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
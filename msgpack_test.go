@@ -0,0 +1,217 @@
+package slim
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func msgpackEncode(t *testing.T, v any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := (MsgpackCodec{}).Encode(&buf, v, ""); err != nil {
+		t.Fatalf("encode %#v: %v", v, err)
+	}
+	return buf.Bytes()
+}
+
+func TestMsgpack_EncodeFormats(t *testing.T) {
+	cases := []struct {
+		v    any
+		want string
+	}{
+		{nil, "c0"},
+		{true, "c3"},
+		{false, "c2"},
+		{0, "00"},
+		{127, "7f"},
+		{128, "cc80"},
+		{256, "cd0100"},
+		{70000, "ce00011170"},
+		{uint64(math.MaxUint64), "cfffffffffffffffff"},
+		{-1, "ff"},
+		{-32, "e0"},
+		{-33, "d0df"},
+		{-200, "d1ff38"},
+		{-70000, "d2fffeee90"},
+		{int64(math.MinInt64), "d38000000000000000"},
+		{float32(1.5), "ca3fc00000"},
+		{1.5, "cb3ff8000000000000"},
+		{"", "a0"},
+		{"abc", "a3616263"},
+		{strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{[]byte{1, 2}, "c4020102"},
+		{[2]byte{1, 2}, "c4020102"},
+		{[]int{1, 2}, "920102"},
+		{[]int(nil), "c0"},
+		{map[string]int{"b": 2, "a": 1}, "82a16101a16202"},
+		{MsgpackExt{Type: 5, Data: []byte{1}}, "d40501"},
+		{MsgpackExt{Type: 5, Data: []byte{1, 2, 3}}, "c70305010203"},
+		{time.Unix(1, 0), "d6ff00000001"},
+		{time.Unix(1, 1), "d7ff0000000400000001"},
+		{time.Unix(-1, 0), "c70cff00000000ffffffffffffffff"},
+	}
+	for _, c := range cases {
+		if got := hex.EncodeToString(msgpackEncode(t, c.v)); got != c.want {
+			t.Errorf("encode %#v = %s, want %s", c.v, got, c.want)
+		}
+	}
+}
+
+type msgpackInner struct {
+	City string `json:"city"`
+}
+
+type msgpackOuter struct {
+	msgpackInner
+	Name    string            `msgpack:"name"`
+	Skip    string            `msgpack:"-"`
+	Empty   string            `json:"empty,omitempty"`
+	Age     uint8             `json:"age"`
+	Score   float64           `msgpack:"score"`
+	Tags    []string          `msgpack:"tags"`
+	Attrs   map[string]any    `msgpack:"attrs"`
+	Born    time.Time         `msgpack:"born"`
+	Ptr     *int              `msgpack:"ptr"`
+	Raw     []byte            `msgpack:"raw"`
+	Ext     MsgpackExt        `msgpack:"ext"`
+	Nested  map[string][]int8 `msgpack:"nested"`
+	private int
+}
+
+func TestMsgpack_RoundTripStruct(t *testing.T) {
+	n := 42
+	in := msgpackOuter{
+		msgpackInner: msgpackInner{City: "x"},
+		Name:         "bob",
+		Skip:         "skipped",
+		Age:          30,
+		Score:        1.25,
+		Tags:         []string{"a", "b"},
+		Attrs:        map[string]any{"k": "v"},
+		Born:         time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Ptr:          &n,
+		Raw:          []byte{0, 1},
+		Ext:          MsgpackExt{Type: 9, Data: []byte("hello")},
+		Nested:       map[string][]int8{"n": {-1, 2}},
+		private:      1,
+	}
+	b := msgpackEncode(t, in)
+
+	var out msgpackOuter
+	if err := (MsgpackCodec{}).Decode(bytes.NewReader(b), &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Skip, in.private = "", 0
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip mismatch:\n in=%+v\nout=%+v", in, out)
+	}
+
+	var generic map[string]any
+	if err := (MsgpackCodec{}).Decode(bytes.NewReader(b), &generic); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := generic["empty"]; ok {
+		t.Fatal("omitempty field was encoded")
+	}
+	if generic["city"] != "x" || generic["age"] != int64(30) || generic["ptr"] != int64(42) {
+		t.Fatalf("generic=%v", generic)
+	}
+	if !generic["born"].(time.Time).Equal(in.Born) {
+		t.Fatalf("born=%v", generic["born"])
+	}
+}
+
+func TestMsgpack_DecodeAny(t *testing.T) {
+	b := msgpackEncode(t, []any{nil, true, -5, uint64(math.MaxUint64), float32(0.5), 2.5, "s", []byte("b"), map[int]string{1: "one"}})
+	var v any
+	if err := (MsgpackCodec{}).Decode(bytes.NewReader(b), &v); err != nil {
+		t.Fatal(err)
+	}
+	want := []any{nil, true, int64(-5), uint64(math.MaxUint64), float32(0.5), 2.5, "s", []byte("b"), map[any]any{int64(1): "one"}}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("v=%#v", v)
+	}
+}
+
+func TestMsgpack_DecodeErrors(t *testing.T) {
+	decode := func(h string, v any) error {
+		b, _ := hex.DecodeString(h)
+		return (MsgpackCodec{}).Decode(bytes.NewReader(b), v)
+	}
+	var i8 int8
+	if err := decode("cc80", &i8); err == nil {
+		t.Error("expected overflow error")
+	}
+	var u uint
+	if err := decode("ff", &u); err == nil {
+		t.Error("expected negative into uint error")
+	}
+	var s string
+	if err := decode("c3", &s); err == nil {
+		t.Error("expected type error")
+	}
+	if err := decode("c1", &s); err == nil {
+		t.Error("expected invalid format error")
+	}
+	if err := decode("a5616263", &s); err == nil {
+		t.Error("expected truncated input error")
+	}
+	// forged lengths must fail without allocating them
+	var a []int
+	if err := decode("ddffffffff01", &a); err == nil {
+		t.Error("expected truncated array error")
+	}
+	if err := decode("dbffffff00", &s); err == nil {
+		t.Error("expected truncated string error")
+	}
+	if err := decode(strings.Repeat("91", msgpackMaxDepth+1)+"c0", new(any)); err == nil {
+		t.Error("expected depth error")
+	}
+	if err := (MsgpackCodec{}).Decode(bytes.NewReader(nil), s); err == nil {
+		t.Error("expected non-pointer error")
+	}
+}
+
+func TestMsgpack_SkipsUnknownFields(t *testing.T) {
+	b := msgpackEncode(t, map[string]any{
+		"name":    "bob",
+		"unknown": map[string]any{"a": []any{1, "x", MsgpackExt{Type: 1, Data: []byte{1, 2, 3}}}},
+		"AGE":     7,
+	})
+	var out msgpackOuter
+	if err := (MsgpackCodec{}).Decode(bytes.NewReader(b), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "bob" || out.Age != 7 {
+		t.Fatalf("out=%+v", out)
+	}
+}
+
+func TestMsgpack_BindAndRespond(t *testing.T) {
+	s := New()
+	s.POST("/", func(c Context) error {
+		var v map[string]any
+		if err := c.Bind(&v); err != nil {
+			return err
+		}
+		return c.Respond(http.StatusOK, v)
+	})
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(msgpackEncode(t, map[string]any{"a": 1})))
+	r.Header.Set(HeaderContentType, MIMEApplicationMsgpack)
+	r.Header.Set(HeaderAccept, MIMEApplicationMsgpack)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get(HeaderContentType) != MIMEApplicationMsgpack {
+		t.Fatalf("code=%d type=%q", w.Code, w.Header().Get(HeaderContentType))
+	}
+	if got := hex.EncodeToString(w.Body.Bytes()); got != "81a16101" {
+		t.Fatalf("body=%s", got)
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
-	"golang.org/x/sync/singleflight"
+	"golang.org/x/text/language"
 )
 
 // MIME types
@@ -24,6 +26,7 @@ const (
 	MIMEApplicationForm                  = "application/x-www-form-urlencoded"
 	MIMEApplicationProtobuf              = "application/protobuf"
 	MIMEApplicationMsgpack               = "application/msgpack"
+	MIMEApplicationCBOR                  = "application/cbor"
 	MIMETextHTML                         = "text/html"
 	MIMETextHTMLCharsetUTF8              = "text/html; charset=UTF-8"
 	MIMETextPlain                        = "text/plain"
@@ -46,11 +49,20 @@ const (
 	HeaderAuthorization       = "Authorization"
 	HeaderContentDisposition  = "Content-Disposition"
 	HeaderContentEncoding     = "Content-Encoding"
+	HeaderContentLanguage     = "Content-Language"
 	HeaderContentLength       = "Content-Length"
 	HeaderContentType         = "Content-Type"
 	HeaderCookie              = "Cookie"
 	HeaderSetCookie           = "Set-Cookie"
 	HeaderIfModifiedSince     = "If-Modified-Since"
+	HeaderIfUnmodifiedSince   = "If-Unmodified-Since"
+	HeaderIfMatch             = "If-Match"
+	HeaderIfNoneMatch         = "If-None-Match"
+	HeaderETag                = "ETag"
+	HeaderRange               = "Range"
+	HeaderIfRange             = "If-Range"
+	HeaderAcceptRanges        = "Accept-Ranges"
+	HeaderContentRange        = "Content-Range"
 	HeaderLastModified        = "Last-Modified"
 	HeaderLocation            = "Location"
 	HeaderUpgrade             = "Upgrade"
@@ -89,11 +101,6 @@ const (
 	HeaderReferrerPolicy                  = "Referrer-Policy"
 )
 
-type cache struct {
-	hint  int
-	slice AcceptSlice
-}
-
 // Negotiator An HTTP content negotiator
 type Negotiator struct {
 	// 缓存容量
@@ -102,11 +109,47 @@ type Negotiator struct {
 	// 不是 W3C 所定义的标准的值，我们
 	// 通过该函数将其重写成标准格式的值。
 	onParse func(*Accept)
-	// 内容协商的报头很少变化，可以使用缓存优化，
-	// 不需要每次解析
-	caches map[string]*cache
-	// 用于合并解析，优化并发
-	sfg singleflight.Group
+	// 内容协商的报头很少变化，使用 LRU 缓存解析结果，
+	// 命中时不需要重新解析，也不产生内存分配。
+	mu      sync.Mutex
+	entries map[string]*acceptEntry
+	// 最近使用的条目位于链表头部，淘汰时移除尾部的条目
+	head, tail *acceptEntry
+	// 缓存命中与未命中的次数
+	hits, misses atomic.Uint64
+	// 语言协商失败时返回的默认语言
+	defaultLanguage string
+	// 解析后的语言标签，应用提供的语言和报头中的语言范围
+	// 都很少变化，缓存后不需要每次都重新解析。
+	languageTags     sync.Map // map[string]languageTag
+	languageTagCount atomic.Int64
+}
+
+// maxLanguageTags 是缓存的语言标签数量上限，
+// 避免恶意构造的报头使缓存无限增长。
+const maxLanguageTags = 1024
+
+// acceptEntry 是 LRU 缓存中的一个条目
+type acceptEntry struct {
+	header     string
+	slice      AcceptSlice
+	prev, next *acceptEntry
+}
+
+// NegotiatorStats 是内容协商器缓存的统计信息
+type NegotiatorStats struct {
+	Hits    uint64 // 命中次数
+	Misses  uint64 // 未命中次数
+	Entries int    // 当前缓存的报头数量
+}
+
+// HitRatio 返回缓存命中率，没有任何查询时返回 0。
+func (s NegotiatorStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
 }
 
 // New 返回内容协商器实例
@@ -120,86 +163,288 @@ func NewNegotiator(capacity int, onParse func(accept *Accept)) *Negotiator {
 	return &Negotiator{
 		capacity: capacity,
 		onParse:  onParse,
-		caches:   make(map[string]*cache),
+		entries:  make(map[string]*acceptEntry, capacity),
 	}
 }
 
-func (n *Negotiator) Slice(header string) AcceptSlice {
-	v, _, _ := n.sfg.Do(header, func() (any, error) {
-		if c, ok := n.caches[header]; ok {
-			c.hint++
-			return c.slice, nil
-		}
-		n.overflow()
-		c := n.parse(header)
-		return c, nil
-	})
-	return v.(AcceptSlice)
-}
-
-func (n *Negotiator) overflow() {
-	if len(n.caches) >= n.capacity {
-		var s string
-		var hint int
-		for i, x := range n.caches {
-			if hint == 0 || hint < x.hint {
-				hint = x.hint
-				s = i
-			}
-		}
-		delete(n.caches, s)
+// Stats 返回缓存的统计信息
+func (n *Negotiator) Stats() NegotiatorStats {
+	n.mu.Lock()
+	entries := len(n.entries)
+	n.mu.Unlock()
+	return NegotiatorStats{
+		Hits:    n.hits.Load(),
+		Misses:  n.misses.Load(),
+		Entries: entries,
 	}
 }
 
-func (n *Negotiator) parse(header string) AcceptSlice {
+// Slice 返回解析后的报头，结果会被缓存并在多个请求之间共享，
+// 调用者不能修改它。
+func (n *Negotiator) Slice(header string) AcceptSlice {
+	n.mu.Lock()
+	if e, ok := n.entries[header]; ok {
+		n.moveToFront(e)
+		n.mu.Unlock()
+		n.hits.Add(1)
+		return e.slice
+	}
+	n.mu.Unlock()
+	n.misses.Add(1)
+
+	// 在锁外解析，避免阻塞其它请求
 	slice := newSlice(header, n.onParse)
-	n.caches[header] = &cache{1, slice}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if e, ok := n.entries[header]; ok {
+		// 其它请求已经缓存了相同的报头
+		n.moveToFront(e)
+		return e.slice
+	}
+	e := &acceptEntry{header: header, slice: slice}
+	n.entries[header] = e
+	n.pushFront(e)
+	if len(n.entries) > n.capacity {
+		oldest := n.tail
+		n.unlink(oldest)
+		delete(n.entries, oldest.header)
+	}
 	return slice
 }
 
+func (n *Negotiator) pushFront(e *acceptEntry) {
+	e.prev, e.next = nil, n.head
+	if n.head != nil {
+		n.head.prev = e
+	}
+	n.head = e
+	if n.tail == nil {
+		n.tail = e
+	}
+}
+
+func (n *Negotiator) unlink(e *acceptEntry) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		n.head = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		n.tail = e.prev
+	}
+	e.prev, e.next = nil, nil
+}
+
+func (n *Negotiator) moveToFront(e *acceptEntry) {
+	if n.head != e {
+		n.unlink(e)
+		n.pushFront(e)
+	}
+}
+
 func (n *Negotiator) Charset(r *http.Request, charsets ...string) string {
 	return n.Accepts(r.Header.Get(HeaderAcceptCharset), charsets...)
 }
 
+// Encoding 按 RFC 9110 从 encodings 中选出 Accept-Encoding 报头可接受且权重最高的编码，
+// 权重相同时以 encodings 中的先后顺序为准。权重为 0 的编码不可接受；报头没有提到的
+// "identity" 总是可接受的（除非被 "*;q=0" 排除），其权重取报头中最低的非零权重。
+// 请求没有该报头时任何编码均可接受，返回 encodings 的第一项；报头为空时只接受 "identity"。
 func (n *Negotiator) Encoding(r *http.Request, encodings ...string) string {
-	return n.Accepts(r.Header.Get(HeaderAcceptEncoding), encodings...)
+	values, ok := r.Header[HeaderAcceptEncoding]
+	if !ok {
+		if len(encodings) > 0 {
+			return encodings[0]
+		}
+		return ""
+	}
+	header := "identity"
+	if len(values) > 0 && strings.TrimSpace(values[0]) != "" {
+		header = values[0]
+	}
+	slice := n.Slice(header)
+	best, bestQuality := "", 0.0
+	for _, encoding := range encodings {
+		if q := encodingQuality(slice, encoding); q > bestQuality {
+			best, bestQuality = encoding, q
+		}
+	}
+	return best
 }
 
+// encodingQuality 返回编码 encoding 在 Accept-Encoding 报头中的权重
+func encodingQuality(slice AcceptSlice, encoding string) float64 {
+	wildcard := -1.0
+	for _, a := range slice {
+		if strings.EqualFold(a.Type, encoding) {
+			return a.Quality
+		}
+		if a.Type == "*" && wildcard < 0 {
+			wildcard = a.Quality
+		}
+	}
+	if wildcard >= 0 {
+		return wildcard
+	}
+	if !strings.EqualFold(encoding, "identity") {
+		return 0
+	}
+	// slice 按权重降序排列，最后一个非零权重就是最低的
+	for i := len(slice) - 1; i >= 0; i-- {
+		if slice[i].Quality > 0 {
+			return slice[i].Quality
+		}
+	}
+	return 1
+}
+
+// Language 按 BCP 47 规则从 languages 中选出与 Accept-Language 报头最匹配的语言。
+// 对报头中的每个语言范围依次尝试完全匹配、前缀匹配（en-GB 匹配 en，en 匹配 en-US）
+// 和忽略地区的匹配（en-GB 匹配 en-US，书写系统需相同），"*" 匹配任意语言，
+// 权重为 0 的语言会被排除。没有匹配时返回默认语言，参见 SetDefaultLanguage。
 func (n *Negotiator) Language(r *http.Request, languages ...string) string {
-	return n.Accepts(r.Header.Get(HeaderAcceptLanguage), languages...)
+	if lang := n.MatchLanguage(r.Header.Get(HeaderAcceptLanguage), languages...); lang != "" {
+		return lang
+	}
+	return n.defaultLanguage
+}
+
+// MatchLanguage 与 Language 的匹配规则相同，header 是 Accept-Language 格式的值，
+// 没有匹配时返回空字符串而不是默认语言。
+func (n *Negotiator) MatchLanguage(header string, languages ...string) string {
+	return n.matchLanguage(header, languages)
+}
+
+// SetDefaultLanguage 设置语言协商失败时返回的默认语言，
+// 需要在处理请求之前调用。
+func (n *Negotiator) SetDefaultLanguage(lang string) {
+	n.defaultLanguage = lang
+}
+
+// languageTag 是解析后的语言标签
+type languageTag struct {
+	tag language.Tag
+	// 规范化并转成小写的标签，用于比较
+	norm string
+	// 是否是合法的 BCP 47 标签
+	valid bool
+}
+
+func parseLanguageTag(s string) languageTag {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "_", "-")
+	tag, err := language.Parse(s)
+	if err != nil {
+		return languageTag{norm: strings.ToLower(s)}
+	}
+	return languageTag{tag: tag, norm: strings.ToLower(tag.String()), valid: true}
+}
+
+// hasLanguagePrefix 报告 prefix 是否是 tag 的前缀，前缀必须以完整的子标签结束。
+func hasLanguagePrefix(tag, prefix string) bool {
+	return strings.HasPrefix(tag, prefix) && (len(tag) == len(prefix) || tag[len(prefix)] == '-')
+}
+
+// sameLanguage 报告两个标签是否是同一语言和书写系统，忽略地区，
+// 所以 zh-TW（繁体）与 zh-CN（简体）不会匹配。
+func sameLanguage(a, b languageTag) bool {
+	if !a.valid || !b.valid {
+		return false
+	}
+	ab, _ := a.tag.Base()
+	bb, _ := b.tag.Base()
+	as, _ := a.tag.Script()
+	bs, _ := b.tag.Script()
+	return ab == bb && as == bs
+}
+
+// 语言匹配的级别，按优先级排列
+const (
+	languageExact  = iota // 完全匹配
+	languagePrefix        // 一方是另一方的前缀
+	languageBase          // 语言和书写系统相同
+)
+
+func matchesLanguage(level int, r, o languageTag) bool {
+	switch level {
+	case languageExact:
+		return r.norm == o.norm
+	case languagePrefix:
+		return hasLanguagePrefix(r.norm, o.norm) || hasLanguagePrefix(o.norm, r.norm)
+	}
+	return sameLanguage(r, o)
+}
+
+// languageTag 返回缓存的语言标签，未缓存时解析并缓存它。
+func (n *Negotiator) languageTag(s string) languageTag {
+	if v, ok := n.languageTags.Load(s); ok {
+		return v.(languageTag)
+	}
+	tag := parseLanguageTag(s)
+	if n.languageTagCount.Load() < maxLanguageTags {
+		if _, loaded := n.languageTags.LoadOrStore(s, tag); !loaded {
+			n.languageTagCount.Add(1)
+		}
+	}
+	return tag
+}
+
+func (n *Negotiator) matchLanguage(header string, languages []string) string {
+	ranges := n.Slice(header)
+	offers := make([]languageTag, len(languages))
+	for i, lang := range languages {
+		offers[i] = n.languageTag(lang)
+	}
+	// 权重为 0 的语言不可接受
+	var excluded []languageTag
+	for _, a := range ranges {
+		if a.Quality == 0 && a.Type != "*" {
+			excluded = append(excluded, n.languageTag(a.Type))
+		}
+	}
+	allowed := func(i int) bool {
+		for _, x := range excluded {
+			if hasLanguagePrefix(offers[i].norm, x.norm) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, a := range ranges {
+		if a.Quality == 0 {
+			// 已按权重降序排列，后面的都不可接受
+			break
+		}
+		if a.Type == "*" {
+			for i := range offers {
+				if allowed(i) {
+					return languages[i]
+				}
+			}
+			continue
+		}
+		r := n.languageTag(a.Type)
+		for level := languageExact; level <= languageBase; level++ {
+			for i, o := range offers {
+				if allowed(i) && matchesLanguage(level, r, o) {
+					return languages[i]
+				}
+			}
+		}
+	}
+	return ""
 }
 
 func (n *Negotiator) Type(r *http.Request, types ...string) string {
 	var keys []string
 	var ctypes []string
 	for _, typ := range types {
-		keys = append(keys, typ)
-		switch typ {
-		case "jsonp":
-			ctypes = append(ctypes, MIMEApplicationJavaScript)
-		case "json":
-			ctypes = append(ctypes, MIMEApplicationJSON)
-		case "xml":
-			keys = append(keys, typ[:])
-			ctypes = append(ctypes, MIMEApplicationXML, MIMETextXML)
-		case "form":
+		for _, ctype := range offerMediaTypes(typ) {
 			keys = append(keys, typ)
-			ctypes = append(ctypes, MIMEMultipartForm, MIMEApplicationForm)
-		case "protobuf":
-			ctypes = append(ctypes, MIMEApplicationProtobuf)
-		case "msgpack":
-			ctypes = append(ctypes, MIMEApplicationMsgpack)
-		case "text", "string":
-			ctypes = append(ctypes, MIMETextPlain)
-		default:
-			if !strings.Contains(typ, "/") {
-				value := mime.TypeByExtension("." + typ)
-				if value != "" {
-					ctypes = append(ctypes, value)
-					continue
-				}
-			}
-			ctypes = append(ctypes, typ[:])
+			ctypes = append(ctypes, ctype)
 		}
 	}
 	s := n.Slice(r.Header.Get(HeaderAccept))
@@ -210,6 +455,35 @@ func (n *Negotiator) Type(r *http.Request, types ...string) string {
 	return ""
 }
 
+// offerMediaTypes 返回类型简称（如 json、xml）对应的媒体类型，
+// 未知的简称按扩展名查找，包含 "/" 的值原样返回。
+func offerMediaTypes(typ string) []string {
+	switch typ {
+	case "jsonp":
+		return []string{MIMEApplicationJavaScript}
+	case "json":
+		return []string{MIMEApplicationJSON}
+	case "xml":
+		return []string{MIMEApplicationXML, MIMETextXML}
+	case "form":
+		return []string{MIMEMultipartForm, MIMEApplicationForm}
+	case "protobuf":
+		return []string{MIMEApplicationProtobuf}
+	case "msgpack":
+		return []string{MIMEApplicationMsgpack}
+	case "cbor":
+		return []string{MIMEApplicationCBOR}
+	case "text", "string":
+		return []string{MIMETextPlain}
+	}
+	if !strings.Contains(typ, "/") {
+		if value := mime.TypeByExtension("." + typ); value != "" {
+			return []string{value}
+		}
+	}
+	return []string{typ}
+}
+
 func (n *Negotiator) Accepts(header string, ctypes ...string) string {
 	s := n.Slice(header)
 	negotiated, _, _ := s.Negotiate(ctypes...)
@@ -286,7 +560,7 @@ func newSlice(header string, onParse func(*Accept)) AcceptSlice {
 			accepted = append(accepted, accept)
 		}
 	}
-	sort.Sort(accepted)
+	sort.Stable(accepted)
 	return accepted
 }
 
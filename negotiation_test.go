@@ -2,6 +2,9 @@ package slim
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -60,3 +63,186 @@ func TestNegotiator_AcceptsAndType(t *testing.T) {
 		t.Fatalf("Type got %s", typ)
 	}
 }
+
+func TestNegotiator_LRU(t *testing.T) {
+	n := NewNegotiator(2, nil)
+	n.Slice("a/a")
+	n.Slice("b/b")
+	n.Slice("a/a") // a is now the most recently used
+	n.Slice("c/c") // evicts b
+	if _, ok := n.entries["b/b"]; ok {
+		t.Fatal("least recently used entry was not evicted")
+	}
+	if _, ok := n.entries["a/a"]; !ok {
+		t.Fatal("recently used entry was evicted")
+	}
+	n.Slice("a/a")
+	st := n.Stats()
+	if st.Hits != 2 || st.Misses != 3 || st.Entries != 2 || st.HitRatio() != 0.4 {
+		t.Fatalf("stats=%+v ratio=%v", st, st.HitRatio())
+	}
+	if (NegotiatorStats{}).HitRatio() != 0 {
+		t.Fatal("empty stats must have a zero hit ratio")
+	}
+	if allocs := testing.AllocsPerRun(100, func() { n.Slice("a/a") }); allocs != 0 {
+		t.Fatalf("cache hit allocates %v times", allocs)
+	}
+}
+
+func TestNegotiator_ConcurrentSlice(t *testing.T) {
+	n := NewNegotiator(8, nil)
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				typ := "type" + strconv.Itoa((g+i)%24)
+				s := n.Slice(typ + "/sub")
+				if len(s) != 1 || s[0].Type != typ {
+					t.Errorf("Slice(%s/sub) = %v", typ, s)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	st := n.Stats()
+	if st.Entries > 8 || st.Hits+st.Misses != 16*2000 {
+		t.Fatalf("stats=%+v", st)
+	}
+	// the list and the map must agree after concurrent use
+	count := 0
+	for e := n.head; e != nil; e = e.next {
+		if n.entries[e.header] != e {
+			t.Fatalf("list entry %q is not in the map", e.header)
+		}
+		count++
+	}
+	if count != len(n.entries) {
+		t.Fatalf("list has %d entries, map has %d", count, len(n.entries))
+	}
+}
+
+func TestNegotiator_Language(t *testing.T) {
+	cases := []struct {
+		header string
+		offers []string
+		want   string
+	}{
+		{"en-US", []string{"fr", "en-US"}, "en-US"},
+		{"EN_us", []string{"fr", "en-US"}, "en-US"},
+		{"en-GB", []string{"fr", "en"}, "en"},
+		{"en", []string{"fr", "en-US"}, "en-US"},
+		{"en-GB", []string{"fr", "en-US"}, "en-US"},
+		{"en-GB, fr;q=0.8", []string{"fr", "en-US"}, "en-US"},
+		{"fr;q=0.5, de", []string{"fr", "de-AT"}, "de-AT"},
+		{"de-CH, en-GB", []string{"en-GB", "de"}, "de"},
+		{"zh-TW", []string{"zh-CN", "zh-HK"}, "zh-HK"},
+		{"zh-TW", []string{"zh-CN"}, ""},
+		{"ja, *;q=0.1", []string{"ko", "en"}, "ko"},
+		{"*, en;q=0", []string{"en-US", "fr"}, "fr"},
+		{"it", []string{"en"}, ""},
+		{"", []string{"en"}, "en"},
+	}
+	n := NewNegotiator(10, nil)
+	for _, c := range cases {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderAcceptLanguage, c.header)
+		if got := n.Language(r, c.offers...); got != c.want {
+			t.Errorf("Language(%q, %v) = %q, want %q", c.header, c.offers, got, c.want)
+		}
+	}
+
+	n.SetDefaultLanguage("en")
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderAcceptLanguage, "it")
+	if got := n.Language(r, "fr", "de"); got != "en" {
+		t.Fatalf("default language = %q", got)
+	}
+}
+
+func TestNegotiator_Encoding(t *testing.T) {
+	cases := []struct {
+		header string
+		offers []string
+		want   string
+	}{
+		{"gzip, br", []string{"br", "gzip"}, "br"},
+		{"gzip;q=0.8, br", []string{"gzip", "br"}, "br"},
+		{"GZIP", []string{"gzip"}, "gzip"},
+		{"gzip;q=0", []string{"gzip", "deflate"}, ""},
+		{"*", []string{"gzip", "deflate"}, "gzip"},
+		{"*;q=0.5, gzip;q=0", []string{"gzip", "deflate"}, "deflate"},
+		{"gzip;q=0.5", []string{"gzip", "identity"}, "gzip"},
+		{"gzip;q=0.5, identity", []string{"gzip", "identity"}, "identity"},
+		{"gzip", []string{"br", "identity"}, "identity"},
+		{"*;q=0", []string{"gzip", "identity"}, ""},
+		{"identity;q=0, *", []string{"identity"}, ""},
+		{"", []string{"gzip", "identity"}, "identity"},
+	}
+	n := NewNegotiator(10, nil)
+	for _, c := range cases {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderAcceptEncoding, c.header)
+		if got := n.Encoding(r, c.offers...); got != c.want {
+			t.Errorf("Encoding(%q, %v) = %q, want %q", c.header, c.offers, got, c.want)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if got := n.Encoding(r, "gzip", "identity"); got != "gzip" {
+		t.Errorf("Encoding without header = %q, want %q", got, "gzip")
+	}
+}
+
+func TestNewSlice_EqualQualityKeepsHeaderOrder(t *testing.T) {
+	// ranges of equal weight interleaved with lower ones, enough of them
+	// for sort.Sort to reorder ranges of equal weight
+	var types, encodings, accept, acceptEncoding []string
+	for i := 0; i < 20; i++ {
+		types = append(types, "application/x-"+strconv.Itoa(i))
+		encodings = append(encodings, "enc"+strconv.Itoa(i))
+		accept = append(accept, types[i], "text/x-"+strconv.Itoa(i)+";q=0.5")
+		acceptEncoding = append(acceptEncoding, encodings[i], "low"+strconv.Itoa(i)+";q=0.5")
+	}
+	check := func(header string, want []string, got func(a Accept) string) {
+		t.Helper()
+		s := newSlice(header, onAcceptParsed)
+		for i, w := range want {
+			if got(s[i]) != w {
+				t.Fatalf("%d: got %q, want %q", i, got(s[i]), w)
+			}
+		}
+	}
+	check(strings.Join(accept, ", "), types, func(a Accept) string { return a.Type + "/" + a.Subtype })
+	check(strings.Join(acceptEncoding, ", "), encodings, func(a Accept) string { return a.Type })
+
+	n := NewNegotiator(10, nil)
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderAcceptEncoding, "br, zstd, gzip")
+	if got := n.Encoding(r, "gzip", "zstd", "br"); got != "gzip" {
+		t.Fatalf("Encoding = %q, equal weights go to the first offer", got)
+	}
+}
+
+func TestNegotiator_LanguageTagCache(t *testing.T) {
+	n := NewNegotiator(10, nil)
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderAcceptLanguage, "en-GB, fr;q=0")
+	for i := 0; i < 3; i++ {
+		if got := n.Language(r, "fr", "en-US"); got != "en-US" {
+			t.Fatalf("Language = %q", got)
+		}
+	}
+	// "fr", "en-US" and "en-GB" are parsed once, "fr" being both an offer and a range
+	if got := n.languageTagCount.Load(); got != 3 {
+		t.Fatalf("cached tags = %d", got)
+	}
+	for i := 0; i < maxLanguageTags+10; i++ {
+		n.MatchLanguage("x-"+strconv.Itoa(i), "en")
+	}
+	if got := n.languageTagCount.Load(); got != maxLanguageTags {
+		t.Fatalf("cached tags = %d, want the limit %d", got, maxLanguageTags)
+	}
+}
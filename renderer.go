@@ -1,6 +1,11 @@
 package slim
 
-import "io"
+import (
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	texttemplate "text/template"
+)
 
 // Renderer is the interface that wraps the Render function.
 type Renderer interface {
@@ -18,12 +23,64 @@ type Renderer interface {
 //			Template: template.Must(template.New("hello").Parse("Hello, {{.}}!")),
 //		}
 type TemplateRenderer struct {
-	Template interface {
-		ExecuteTemplate(wr io.Writer, name string, data any) error
-	}
+	Template templateExecutor
+	// Funcs returns template functions bound to the request, such as
+	// translation functions using the request locale. When set, Template must
+	// be a `*html/template.Template` or `*text/template.Template`; it is
+	// cloned once per request and the functions are added to the clone. The
+	// functions must be defined when parsing.
+	Funcs func(c Context) map[string]any
+}
+
+type templateExecutor interface {
+	ExecuteTemplate(wr io.Writer, name string, data any) error
 }
 
+// requestTemplate is the template cloned for a request by a renderer.
+type requestTemplate struct {
+	renderer *TemplateRenderer
+	tmpl     templateExecutor
+}
+
+var requestTemplateKey = NewKey[requestTemplate]("request template")
+
+var errTemplateFuncs = errors.New("slim: template funcs require an html/template or text/template template")
+
 // Render renders the template with given data.
-func (t *TemplateRenderer) Render(_ Context, w io.Writer, name string, data any) error {
-	return t.Template.ExecuteTemplate(w, name, data)
+func (t *TemplateRenderer) Render(c Context, w io.Writer, name string, data any) error {
+	var tmpl templateExecutor = t.Template
+	if t.Funcs != nil {
+		var err error
+		if tmpl, err = t.requestTemplate(c); err != nil {
+			return err
+		}
+	}
+	return tmpl.ExecuteTemplate(w, name, data)
+}
+
+// requestTemplate returns the clone of Template holding the functions bound
+// to the request, reusing it when the request renders more than once.
+func (t *TemplateRenderer) requestTemplate(c Context) (templateExecutor, error) {
+	if rt, ok := requestTemplateKey.Lookup(c); ok && rt.renderer == t {
+		return rt.tmpl, nil
+	}
+	var tmpl templateExecutor
+	switch x := t.Template.(type) {
+	case *htmltemplate.Template:
+		clone, err := x.Clone()
+		if err != nil {
+			return nil, err
+		}
+		tmpl = clone.Funcs(t.Funcs(c))
+	case *texttemplate.Template:
+		clone, err := x.Clone()
+		if err != nil {
+			return nil, err
+		}
+		tmpl = clone.Funcs(t.Funcs(c))
+	default:
+		return nil, errTemplateFuncs
+	}
+	requestTemplateKey.Set(c, requestTemplate{t, tmpl})
+	return tmpl, nil
 }
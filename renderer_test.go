@@ -2,9 +2,14 @@ package slim
 
 import (
 	"errors"
+	"fmt"
+	htmltemplate "html/template"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"text/template"
 )
 
 type fakeTmpl struct{
@@ -46,3 +51,57 @@ func TestTemplateRenderer_Render_Error(t *testing.T) {
 		t.Fatalf("expected error from ExecuteTemplate")
 	}
 }
+
+func TestTemplateRenderer_Funcs(t *testing.T) {
+	tmpl := template.Must(template.New("hi").Funcs(template.FuncMap{"greet": func() string { return "" }}).Parse(`{{greet}} {{.}}`))
+	r := &TemplateRenderer{
+		Template: tmpl,
+		Funcs: func(c Context) map[string]any {
+			return map[string]any{"greet": func() string { return c.T("hello") }}
+		},
+	}
+	s := New()
+	c := s.NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	var b strings.Builder
+	if err := r.Render(c, &b, "hi", "Ann"); err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	if b.String() != "hello Ann" {
+		t.Fatalf("unexpected render output: %q", b.String())
+	}
+
+	c = s.NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	r.Template = &fakeTmpl{}
+	if err := r.Render(c, &b, "hi", nil); err != errTemplateFuncs {
+		t.Fatalf("expected errTemplateFuncs, got %v", err)
+	}
+}
+
+func TestTemplateRenderer_FuncsClonedOncePerRequest(t *testing.T) {
+	tmpl := htmltemplate.Must(htmltemplate.New("hi").Funcs(htmltemplate.FuncMap{"n": func() int { return 0 }}).Parse(`<b>{{n}}</b>`))
+	calls := 0
+	r := &TemplateRenderer{
+		Template: tmpl,
+		Funcs: func(c Context) map[string]any {
+			calls++
+			n := calls
+			return map[string]any{"n": func() int { return n }}
+		},
+	}
+	s := New()
+	for req := 1; req <= 2; req++ {
+		c := s.NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		for i := 0; i < 2; i++ {
+			var b strings.Builder
+			if err := r.Render(c, &b, "hi", nil); err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+			if want := fmt.Sprintf("<b>%d</b>", req); b.String() != want {
+				t.Fatalf("expected %q, got %q", want, b.String())
+			}
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("expected Funcs to be called once per request, got %d calls", calls)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
@@ -144,6 +143,9 @@ type RouteRegistrar interface {
 	// Static registers a new route with path prefix to serve static files
 	// from the provided root directory. Panics on error.
 	Static(prefix, root string) Route
+	// StaticWithConfig registers a new route with path prefix to serve static
+	// files as configured by config. Panics on error.
+	StaticWithConfig(prefix string, config StaticConfig) Route
 	// File registers a new route with a path to serve a static file.
 	// Panics on error.
 	File(pattern, file string) Route
@@ -382,6 +384,10 @@ func (r *routerImpl) Static(prefix, root string) Route {
 	return r.collector.Static(prefix, root)
 }
 
+func (r *routerImpl) StaticWithConfig(prefix string, config StaticConfig) Route {
+	return r.collector.StaticWithConfig(prefix, config)
+}
+
 func (r *routerImpl) File(pattern, file string) Route {
 	return r.collector.File(pattern, file)
 }
@@ -426,7 +432,8 @@ func (r *routerImpl) Match(req *http.Request, pathParams *PathParams) RouteMatch
 	*pathParams = (*pathParams)[0:tail.leaf.paramsCount]
 	var ep *endpoint
 	result.AllowMethods, ep = tail.leaf.match(req.Method)
-	if ep == nil || (ep.trailingSlash != tailingSlash && !r.routingTrailingSlash) {
+	// 以 * 通配符结尾的路由把斜线当作参数值的一部分
+	if ep == nil || (ep.trailingSlash != tailingSlash && !r.routingTrailingSlash && !endsWithAny(ep.pattern)) {
 		// TODO(hupeh): 在使用 OPTIONS 方法的情况下，可以使用该节点拥有的方法列表进行响应。
 		// FIXME: See https://httpwg.org/specs/rfc7231.html#OPTIONS
 		result.Type = RouteMatchMethodNotAllowed
@@ -496,6 +503,12 @@ func (r *routerImpl) Match(req *http.Request, pathParams *PathParams) RouteMatch
 	return result
 }
 
+// endsWithAny 判断路由的最后一段是否为 * 通配符
+func endsWithAny(pattern string) bool {
+	i := strings.LastIndexByte(pattern, pathSeparator)
+	return i+1 < len(pattern) && pattern[i+1] == anyLabel
+}
+
 func (r *routerImpl) HandleError(c Context, err error) {
 	if r.errorHandler != nil {
 		r.errorHandler.HandleError(c, err)
@@ -699,7 +712,41 @@ func (rc *routeCollectorImpl) Handle(method string, pattern string, h HandlerFun
 }
 
 func (rc *routeCollectorImpl) Static(prefix, root string) Route {
-	return rc.GET(prefix+"*", StaticDirectoryHandler(root, false))
+	return rc.StaticWithConfig(prefix, StaticConfig{Root: root})
+}
+
+func (rc *routeCollectorImpl) StaticWithConfig(prefix string, config StaticConfig) Route {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	h := StaticDirectoryHandlerWithConfig(config)
+	// 通配符不匹配空路径，前缀本身单独注册，以便提供首页
+	files := rc.GET(prefix+"*", h)
+	return &staticRoute{Route: files, index: rc.GET(prefix, h)}
+}
+
+// staticRoute 静态文件路由，由通配符路由和前缀路由组成，
+// 中间件、名称和标题同时作用于两者
+type staticRoute struct {
+	Route
+	index Route
+}
+
+func (r *staticRoute) Use(middleware ...MiddlewareFunc) {
+	r.Route.Use(middleware...)
+	r.index.Use(middleware...)
+}
+
+func (r *staticRoute) SetName(name string) Route {
+	r.Route.SetName(name)
+	r.index.SetName(name)
+	return r
+}
+
+func (r *staticRoute) SetTitle(title string) Route {
+	r.Route.SetTitle(title)
+	r.index.SetTitle(title)
+	return r
 }
 
 func (rc *routeCollectorImpl) File(pattern, file string) Route {
@@ -711,10 +758,21 @@ func (rc *routeCollectorImpl) File(pattern, file string) Route {
 }
 
 // StaticDirectoryHandler creates handler function to serve files from given a root path
+// Precompressed siblings of the files (.br, .zst and .gz) are served when the client
+// accepts their encoding, see `Static`.
 func StaticDirectoryHandler(root string, disablePathUnescaping bool) HandlerFunc {
-	if root == "" {
-		root = "." // For security, we want to restrict to CWD.
-	}
+	return staticDirectoryHandler(StaticConfig{Root: root}, disablePathUnescaping)
+}
+
+// StaticDirectoryHandlerWithConfig creates handler function to serve files with config,
+// the route must end with the "*" wildcard holding the file path. Root is a directory of
+// config.FS or, when it is nil, of `Context.Filesystem`.
+func StaticDirectoryHandlerWithConfig(config StaticConfig) HandlerFunc {
+	return staticDirectoryHandler(config, false)
+}
+
+func staticDirectoryHandler(config StaticConfig, disablePathUnescaping bool) HandlerFunc {
+	config.init()
 	return func(c Context) error {
 		p := c.PathParam("*")
 		if !disablePathUnescaping { // when router is already unescaping, we do not want to do is twice
@@ -724,21 +782,7 @@ func StaticDirectoryHandler(root string, disablePathUnescaping bool) HandlerFunc
 			}
 			p = tmpPath
 		}
-
-		name := filepath.Join(root, filepath.Clean("/"+p)) // "/"+ for security
-		fi, err := fs.Stat(c.Filesystem(), name)
-		if err != nil {
-			// The access path does not exist
-			return ErrNotFound
-		}
-
-		// If the request is for a directory and does not end with "/"
-		p = c.Request().URL.Path // the path must not be empty.
-		if fi.IsDir() && p[len(p)-1] != '/' {
-			// Redirect to end with "/"
-			return c.Redirect(http.StatusMovedPermanently, p+"/")
-		}
-		return c.File(name)
+		return config.serve(c, p, nil)
 	}
 }
 
@@ -201,3 +201,54 @@ func TestRouter_Any_WithWildcard(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_AnyWildcardKeepsTrailingSlash(t *testing.T) {
+	s := newSlimTest()
+	s.GET("/files/*", func(c Context) error { return c.String(http.StatusOK, c.PathParam("*")) })
+	s.GET("/exact", func(c Context) error { return c.String(http.StatusOK, "exact") })
+	for target, want := range map[string]string{"/files/a/b": "a/b", "/files/a/b/": "a/b/", "/files/sub/": "sub/"} {
+		rw := perform(t, s, http.MethodGet, target, nil, nil)
+		if rw.Code != http.StatusOK || rw.Body.String() != want {
+			t.Fatalf("%s: code=%d body=%q", target, rw.Code, rw.Body.String())
+		}
+	}
+	if rw := perform(t, s, http.MethodGet, "/exact/", nil, nil); rw.Code == http.StatusOK {
+		t.Fatalf("static route must keep strict trailing slash matching")
+	}
+}
+
+func TestRouter_AnyWildcardTrailingSlash(t *testing.T) {
+	for _, tolerant := range []bool{false, true} {
+		s := newSlimTest()
+		r := NewRouter(RouterConfig{RoutingTrailingSlash: tolerant})
+		if x, ok := r.(*routerImpl); ok {
+			x.slim = s
+		}
+		s.router = r
+		s.GET("/x/*", func(c Context) error { return c.String(http.StatusOK, c.PathParam("*")) })
+		s.GET("/y/:id", func(c Context) error { return c.String(http.StatusOK, c.PathParam("id")) })
+		cases := []struct {
+			target string
+			code   int
+			body   string
+		}{
+			{"/x/a", http.StatusOK, "a"},
+			{"/x/a/", http.StatusOK, "a/"},
+			{"/x/a/b", http.StatusOK, "a/b"},
+			{"/x/a/b/", http.StatusOK, "a/b/"},
+			{"/x/", http.StatusNotFound, ""},
+			{"/x", http.StatusNotFound, ""},
+		}
+		for _, tc := range cases {
+			rw := perform(t, s, http.MethodGet, tc.target, nil, nil)
+			if rw.Code != tc.code || tc.code == http.StatusOK && rw.Body.String() != tc.body {
+				t.Fatalf("tolerant=%v %s: code=%d body=%q", tolerant, tc.target, rw.Code, rw.Body.String())
+			}
+		}
+		// other routes keep their trailing slash rule
+		rw := perform(t, s, http.MethodGet, "/y/1/", nil, nil)
+		if tolerant && rw.Code != http.StatusOK || !tolerant && rw.Code == http.StatusOK {
+			t.Fatalf("tolerant=%v /y/1/: code=%d", tolerant, rw.Code)
+		}
+	}
+}
@@ -127,10 +127,13 @@ type Slim struct {
 	ErrorHandler         ErrorHandlerFunc
 	Filesystem           fs.FS // 静态资源文件系统，默认值 `os.DirFS(".")`。
 	Binder               Binder
-	Validator            Validator
-	Renderer             Renderer // 自定义模板渲染器
+	BindOptions          BindOptions // 请求体绑定选项，可通过 `WithBindOptions` 按路由覆盖。
+	Validator            Validator   // 数据校验器，默认值 nil，可设置 `s.Validator = slim.NewValidator()` 启用。
+	Renderer             Renderer    // 自定义模板渲染器
+	Translator           Translator  // 消息翻译器，供 `Context.T` 使用，参见 i18n 包。
 	JSONCodec            Codec
 	XMLCodec             Codec
+	Codecs               *Codecs // 按媒体类型注册的编解码器，默认包含 JSON、XML、MessagePack 和 CBOR。
 	Server               *http.Server
 	TLSServer            *http.Server
 	Listener             net.Listener
@@ -170,6 +173,12 @@ func New() *Slim {
 		PrettyIndent:         "  ",
 		JSONPCallbacks:       []string{"jsonp", "callback"},
 	}
+	s.Codecs = NewCodecs()
+	s.Codecs.Register(MIMEApplicationJSON, slimCodec(func() Codec { return s.JSONCodec }))
+	s.Codecs.Register(MIMEApplicationXML, slimCodec(func() Codec { return s.XMLCodec }))
+	s.Codecs.Register(MIMETextXML, slimCodec(func() Codec { return s.XMLCodec }))
+	s.Codecs.Register(MIMEApplicationMsgpack, MsgpackCodec{})
+	s.Codecs.Register(MIMEApplicationCBOR, CBORCodec{})
 	s.Server.Handler = s
 	s.TLSServer.Handler = s
 	s.router = s.NewRouter()
@@ -188,7 +197,7 @@ func (s *Slim) NewContext(w http.ResponseWriter, r *http.Request) Context {
 		request:       r,
 		response:      nil,
 		allowsMethods: make([]string, 0),
-		store:         make(Map),
+		store:         make(map[any]any),
 		slim:          s,
 		pathParams:    &p,
 		matchType:     RouteMatchUnknown,
@@ -331,6 +340,12 @@ func (s *Slim) Static(prefix, root string) Route {
 	return s.router.Static(prefix, root)
 }
 
+// StaticWithConfig registers a new route with path prefix to serve static
+// files as configured by config. Panics on error.
+func (s *Slim) StaticWithConfig(prefix string, config StaticConfig) Route {
+	return s.router.StaticWithConfig(prefix, config)
+}
+
 // File registers a new route with a path to serve a static file.
 // Panics on error.
 func (s *Slim) File(path, file string) Route {
@@ -2,11 +2,16 @@ package slim
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"runtime"
+	"strings"
 )
 
 type StaticConfig struct {
@@ -20,24 +25,69 @@ type StaticConfig struct {
 	// SPA (single-page application) can handle the routing.
 	// Optional. Default value is false.
 	HTML5 bool
-	// Filesystem provides access to the static content.
-	// Optional. Default to http.Dir(config.Root)
+	// HTML5Exclude lists the URL path prefixes, such as "/api", that HTML5
+	// mode does not forward to the index file, so that unknown API
+	// endpoints still answer 404.
+	// Optional. Default value is nil.
+	HTML5Exclude []string
+	// FS provides access to the static content, such as an embed.FS, Root
+	// being a directory of it.
+	// Optional. Default to http.Dir(config.Root) for the middleware and to
+	// `Context.Filesystem` for the routes.
+	FS fs.FS
+	// Filesystem provides access to the static content when FS is nil.
+	//
+	// Deprecated: Use FS instead.
 	Filesystem http.FileSystem
+	// CacheControl sets the Cache-Control header of the files matching the
+	// patterns, the first matching rule wins.
+	// Optional. Default value is nil.
+	CacheControl []StaticCacheRule
+	// DenyHidden answers 404 for the files and directories whose name starts
+	// with a dot, such as ".env" or ".git/config".
+	// Optional. Default value is false.
+	DenyHidden bool
+	// Browse lists the content of directories without an index file, as
+	// HTML or as JSON depending on the Accept header, see DirectoryListing.
+	// Optional. Default value is false.
+	Browse bool
+	// BrowseTemplate is the name of the template rendered by `Slim.Renderer`
+	// with a DirectoryListing for HTML listings.
+	// Optional. Default to a built-in template.
+	BrowseTemplate string
+	// ShowHidden lists the files whose name starts with a dot.
+	// Optional. Default value is false.
+	ShowHidden bool
+}
+
+// StaticCacheRule sets the Cache-Control header of the static files matching
+// Pattern, for example:
+//
+//	[]slim.StaticCacheRule{
+//		{Pattern: "index.html", CacheControl: "no-cache"},
+//		{Pattern: "assets/*", CacheControl: "public, max-age=31536000, immutable"},
+//	}
+type StaticCacheRule struct {
+	// Pattern is a `path.Match` pattern. A pattern without a slash matches
+	// the file name in any directory, otherwise it matches the file path
+	// relative to the root.
+	Pattern string
+	// CacheControl is the value of the Cache-Control header.
+	CacheControl string
 }
 
 // Static returns Static middleware to serve static content from the provided
 // root directory.
+//
+// Precompressed siblings of a file, such as "app.js.br", "app.js.zst" or
+// "app.js.gz" for "app.js", are served instead of the file when the client
+// accepts their encoding.
 func Static(root string) MiddlewareFunc {
 	return StaticConfig{Root: root}.ToMiddleware()
 }
 
 func (config StaticConfig) ToMiddleware() MiddlewareFunc {
-	if config.Root == "" {
-		config.Root = "." // For security, we want to restrict to CWD.
-	}
-	if config.Index == "" {
-		config.Index = "index.html"
-	}
+	config.init()
 	if config.Filesystem == nil {
 		config.Filesystem = http.Dir(config.Root)
 		config.Root = "."
@@ -48,64 +98,258 @@ func (config StaticConfig) ToMiddleware() MiddlewareFunc {
 		if err != nil {
 			return err
 		}
+		return config.serve(c, p, next)
+	}
+}
 
-		name := path.Join(config.Root, path.Clean("/"+p)) // "/"+ for security
+// init fills in the defaults and checks the cache rules, it panics on an
+// invalid pattern.
+func (config *StaticConfig) init() {
+	if config.Root == "" {
+		config.Root = "." // For security, we want to restrict to CWD.
+	}
+	if config.Index == "" {
+		config.Index = "index.html"
+	}
+	if config.FS != nil {
+		config.Filesystem = http.FS(config.FS)
+	}
+	for _, rule := range config.CacheControl {
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			panic(fmt.Errorf("slim: invalid static cache pattern %q: %w", rule.Pattern, err))
+		}
+	}
+}
 
-		file, err := config.Filesystem.Open(name)
-		if err != nil {
-			if !isIgnorableOpenFileError(err) {
-				return err
-			}
+// serve serves the file p, a path relative to the root, for both the Static
+// middleware and the static route handlers. The middleware passes the
+// requests that match no file down to next, the route handlers have no next
+// and answer them with 404.
+func (config StaticConfig) serve(c Context, p string, next HandlerFunc) error {
+	filesystem := config.Filesystem
+	if filesystem == nil {
+		filesystem = http.FS(c.Filesystem())
+	}
+	rel := path.Clean("/" + p) // "/"+ for security
+	if config.DenyHidden && strings.Contains(rel, "/.") {
+		return config.skip(c, next)
+	}
 
-			// file with that path did not exist, so we continue down in a middleware/handler chain,
-			// hoping that we end up in handler that is meant to handle this request
-			if err = next(c); err == nil {
-				return err
-			}
+	name := path.Join("/", config.Root, rel)
+	file, err := filesystem.Open(name)
+	if err != nil {
+		if next != nil && !isIgnorableOpenFileError(err) {
+			return err
+		}
+		return config.fallback(c, filesystem, next)
+	}
+	defer file.Close()
 
-			var he *HTTPError
-			if !(errors.As(err, &he) && config.HTML5 && he.Code == http.StatusNotFound) {
-				return err
-			}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
 
-			file, err = config.Filesystem.Open(path.Join(config.Root, config.Index))
-			if err != nil {
-				return err
+	if info.IsDir() {
+		if next == nil {
+			// If the request is for a directory and does not end with "/"
+			if p = c.Request().URL.Path; !strings.HasSuffix(p, "/") {
+				// Redirect to end with "/"
+				return c.Redirect(http.StatusMovedPermanently, p+"/")
 			}
 		}
+		index, err := filesystem.Open(path.Join(name, config.Index))
+		if err != nil {
+			if config.Browse {
+				return serveDirectoryListing(c, filesystem, name, config)
+			}
+			return config.skip(c, next)
+		}
+		defer index.Close()
+
+		if info, err = index.Stat(); err != nil {
+			return err
+		}
+		name, file, rel = path.Join(name, config.Index), index, path.Join(rel, config.Index)
+	}
 
-		defer file.Close()
+	config.setCacheControl(c, rel)
+	return serveFile(c, filesystem, name, file, info)
+}
 
-		info, err := file.Stat()
-		if err != nil {
+// skip hands the request over to next, or answers 404 without one.
+func (config StaticConfig) skip(c Context, next HandlerFunc) error {
+	if next != nil {
+		return next(c)
+	}
+	return ErrNotFound
+}
+
+// fallback handles a request that matches no file. In HTML5 mode the index
+// file of the root answers the requests that would otherwise get a 404.
+func (config StaticConfig) fallback(c Context, filesystem http.FileSystem, next HandlerFunc) error {
+	var err error = ErrNotFound
+	if next != nil {
+		// file with that path did not exist, so we continue down in a middleware/handler chain,
+		// hoping that we end up in handler that is meant to handle this request
+		if err = next(c); err == nil {
 			return err
 		}
+	}
 
-		if info.IsDir() {
-			index, err := config.Filesystem.Open(path.Join(name, config.Index))
-			if err != nil {
-				return next(c)
-			}
+	var he *HTTPError
+	if !(errors.As(err, &he) && config.HTML5 && he.Code == http.StatusNotFound) ||
+		config.excluded(c.Request().URL.Path) {
+		return err
+	}
 
-			defer index.Close()
+	name := path.Join("/", config.Root, config.Index)
+	file, ferr := filesystem.Open(name)
+	if ferr != nil {
+		if next == nil {
+			return ErrNotFound
+		}
+		return ferr
+	}
+	defer file.Close()
 
-			info, err = index.Stat()
-			if err != nil {
-				return err
-			}
+	info, ferr := file.Stat()
+	if ferr != nil {
+		return ferr
+	}
+	config.setCacheControl(c, "/"+config.Index)
+	return serveFile(c, filesystem, name, file, info)
+}
 
-			return serveFile(c, index, info)
+// excluded reports whether HTML5 mode leaves the URL path p alone.
+func (config StaticConfig) excluded(p string) bool {
+	for _, prefix := range config.HTML5Exclude {
+		if rest, ok := strings.CutPrefix(p, prefix); ok &&
+			(rest == "" || rest[0] == '/' || strings.HasSuffix(prefix, "/")) {
+			return true
 		}
+	}
+	return false
+}
 
-		return serveFile(c, file, info)
+// setCacheControl applies the first cache rule matching rel, the path of the
+// served file relative to the root.
+func (config StaticConfig) setCacheControl(c Context, rel string) {
+	rel = strings.TrimPrefix(rel, "/")
+	for _, rule := range config.CacheControl {
+		name := rel
+		if !strings.Contains(rule.Pattern, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(rule.Pattern, name); ok {
+			c.SetHeader(HeaderCacheControl, rule.CacheControl)
+			return
+		}
 	}
 }
 
-func serveFile(c Context, file http.File, info os.FileInfo) error {
+func serveFile(c Context, fsys http.FileSystem, name string, file http.File, info os.FileInfo) error {
+	if served, err := servePrecompressed(c, fsys, name); served || err != nil {
+		return err
+	}
 	http.ServeContent(c.Response(), c.Request(), info.Name(), info.ModTime(), file)
 	return nil
 }
 
+// precompressedFiles lists the extensions of precompressed files by content
+// coding, in order of preference.
+var precompressedFiles = []struct{ encoding, ext string }{
+	{"br", ".br"},
+	{"zstd", ".zst"},
+	{"gzip", ".gz"},
+}
+
+// servePrecompressed serves the precompressed sibling of the file name, such
+// as "app.js.gz" for "app.js", whose encoding the client prefers, with the
+// content type of the original file. It reports whether a file was served.
+func servePrecompressed(c Context, fsys http.FileSystem, name string) (bool, error) {
+	var (
+		encodings [3]string
+		files     [3]http.File
+		n         int
+	)
+	for _, p := range precompressedFiles {
+		f, err := fsys.Open(name + p.ext)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		if info, err := f.Stat(); err != nil || info.IsDir() {
+			continue
+		}
+		encodings[n], files[n] = p.encoding, f
+		n++
+	}
+	if n == 0 {
+		return false, nil
+	}
+
+	c.Vary(HeaderAcceptEncoding)
+	r := c.Request()
+	if r.Header.Get(HeaderAcceptEncoding) == "" {
+		return false, nil
+	}
+	encoding := c.Slim().Negotiator().Encoding(r, append(encodings[:n], "identity")...)
+	i := 0
+	for i < n && encodings[i] != encoding {
+		i++
+	}
+	if i == n {
+		return false, nil
+	}
+	info, err := files[i].Stat()
+	if err != nil {
+		return false, err
+	}
+
+	header := c.Response().Header()
+	if _, ok := header[HeaderContentType]; !ok {
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			// sniff the original file, the compressed one says nothing
+			if ctype, err = sniffFile(fsys, name); err != nil {
+				return false, err
+			}
+		}
+		header.Set(HeaderContentType, ctype)
+	}
+	header.Set(HeaderContentEncoding, encoding)
+	// each encoding is a distinct representation with its own entity tag
+	if etag := header.Get(HeaderETag); strings.HasSuffix(etag, `"`) && len(etag) > 1 {
+		header.Set(HeaderETag, etag[:len(etag)-1]+"-"+encoding+`"`)
+	}
+	http.ServeContent(c.Response(), r, path.Base(name), info.ModTime(), files[i])
+	return true, nil
+}
+
+func sniffFile(fsys http.FileSystem, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var buf [512]byte
+	n, err := io.ReadFull(f, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+func statFile(fsys http.FileSystem, name string) (os.FileInfo, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
 // We ignore these errors as there could be handler that matches request path.
 func isIgnorableOpenFileError(err error) bool {
 	if os.IsNotExist(err) {
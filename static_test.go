@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"testing/fstest"
 )
 
 func writeFile(t *testing.T, dir, name, content string) string {
@@ -85,3 +86,268 @@ func TestIsIgnorableOpenFileError(t *testing.T) {
 		t.Fatalf("expected true for os.ErrNotExist")
 	}
 }
+
+func TestStatic_Precompressed(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "app.js", "console.log('raw')")
+	writeFile(t, dir, "app.js.gz", "gzip-bytes")
+	writeFile(t, dir, "app.js.br", "brotli-bytes")
+	writeFile(t, dir, "data.unknownext", "plain text data")
+	writeFile(t, dir, "data.unknownext.zst", "zstd-bytes")
+	writeFile(t, dir, "plain.txt", "no siblings")
+	writeFile(t, dir, "docs/index.html", "<p>raw</p>")
+	writeFile(t, dir, "docs/index.html.gz", "gzip-index")
+
+	s := New()
+	s.Use(Static(dir))
+
+	cases := []struct {
+		path, accept, encoding, body, ctype string
+	}{
+		{"/app.js", "gzip", "gzip", "gzip-bytes", "text/javascript; charset=utf-8"},
+		{"/app.js", "gzip, br", "br", "brotli-bytes", "text/javascript; charset=utf-8"},
+		{"/app.js", "br;q=0.5, gzip", "gzip", "gzip-bytes", "text/javascript; charset=utf-8"},
+		{"/app.js", "gzip;q=0, br;q=0", "", "console.log('raw')", "text/javascript; charset=utf-8"},
+		{"/app.js", "deflate", "", "console.log('raw')", "text/javascript; charset=utf-8"},
+		{"/app.js", "", "", "console.log('raw')", "text/javascript; charset=utf-8"},
+		{"/data.unknownext", "zstd", "zstd", "zstd-bytes", "text/plain; charset=utf-8"},
+		{"/docs/", "gzip", "gzip", "gzip-index", "text/html; charset=utf-8"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.accept != "" {
+			r.Header.Set(HeaderAcceptEncoding, c.accept)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		h := w.Header()
+		if w.Code != http.StatusOK || w.Body.String() != c.body || h.Get(HeaderContentEncoding) != c.encoding ||
+			h.Get(HeaderContentType) != c.ctype || h.Get(HeaderVary) != HeaderAcceptEncoding {
+			t.Errorf("%s accept=%q: code=%d body=%q headers=%v", c.path, c.accept, w.Code, w.Body.String(), h)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/plain.txt", nil)
+	r.Header.Set(HeaderAcceptEncoding, "gzip")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Header().Get(HeaderVary) != "" || w.Body.String() != "no siblings" {
+		t.Errorf("plain.txt: body=%q headers=%v", w.Body.String(), w.Header())
+	}
+}
+
+func TestStatic_PrecompressedRangeAndConditional(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "app.css", "body{}")
+	writeFile(t, dir, "app.css.gz", "0123456789")
+
+	s := New()
+	s.Use(Static(dir))
+
+	r := httptest.NewRequest(http.MethodGet, "/app.css", nil)
+	r.Header.Set(HeaderAcceptEncoding, "gzip")
+	r.Header.Set("Range", "bytes=2-5")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" || w.Header().Get(HeaderContentEncoding) != "gzip" {
+		t.Fatalf("range: code=%d body=%q headers=%v", w.Code, w.Body.String(), w.Header())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/app.css", nil)
+	r.Header.Set(HeaderAcceptEncoding, "gzip")
+	r.Header.Set(HeaderIfModifiedSince, w.Header().Get(HeaderLastModified))
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("conditional: code=%d", w.Code)
+	}
+}
+
+func TestStaticDirectoryHandler_Precompressed(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "site/docs/index.html", "<p>raw</p>")
+	writeFile(t, dir, "site/docs/index.html.br", "brotli-index")
+
+	s := New()
+	s.Filesystem = os.DirFS(dir)
+	s.Static("/static/", "site")
+
+	r := httptest.NewRequest(http.MethodGet, "/static/docs/index.html", nil)
+	r.Header.Set(HeaderAcceptEncoding, "br")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Body.String() != "brotli-index" || w.Header().Get(HeaderContentEncoding) != "br" ||
+		w.Header().Get(HeaderContentType) != "text/html; charset=utf-8" {
+		t.Fatalf("code=%d body=%q headers=%v", w.Code, w.Body.String(), w.Header())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/static/docs/index.html", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Header().Get(HeaderContentEncoding) != "" || w.Header().Get(HeaderVary) != HeaderAcceptEncoding {
+		t.Fatalf("identity: code=%d body=%q headers=%v", w.Code, w.Body.String(), w.Header())
+	}
+}
+
+func TestRouterStatic_Options(t *testing.T) {
+	s := newSlimTest()
+	s.GET("/api/users", func(c Context) error { return c.String(http.StatusOK, "users") })
+	s.StaticWithConfig("/", StaticConfig{
+		Root: "dist",
+		FS:   fstest.MapFS{
+			"dist/main.html":       {Data: []byte("<p>app</p>")},
+			"dist/assets/app.js":   {Data: []byte("js")},
+			"dist/docs/guide.html": {Data: []byte("guide")},
+			"dist/.env":            {Data: []byte("SECRET=1")},
+			"dist/.git/config":     {Data: []byte("[core]")},
+		},
+		Index:        "main.html",
+		HTML5:        true,
+		HTML5Exclude: []string{"/api"},
+		CacheControl: []StaticCacheRule{
+			{Pattern: "main.html", CacheControl: "no-cache"},
+			{Pattern: "assets/*", CacheControl: "public, max-age=31536000, immutable"},
+		},
+		DenyHidden: true,
+	})
+
+	tests := []struct {
+		target, body, cacheControl string
+		code                       int
+	}{
+		{"/", "<p>app</p>", "no-cache", http.StatusOK},
+		{"/assets/app.js", "js", "public, max-age=31536000, immutable", http.StatusOK},
+		{"/docs/guide.html", "guide", "", http.StatusOK},
+		// the SPA routes get the index file
+		{"/users/42", "<p>app</p>", "no-cache", http.StatusOK},
+		{"/api/users", "users", "", http.StatusOK},
+		{"/api/unknown", "", "", http.StatusNotFound},
+		{"/apidocs", "<p>app</p>", "no-cache", http.StatusOK},
+		{"/.env", "", "", http.StatusNotFound},
+		{"/.git/config", "", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := perform(t, s, http.MethodGet, tt.target, nil, nil)
+		if rec.Code != tt.code || rec.Code == http.StatusOK && rec.Body.String() != tt.body ||
+			rec.Header().Get(HeaderCacheControl) != tt.cacheControl {
+			t.Errorf("%s: code=%d body=%q cache-control=%q", tt.target, rec.Code, rec.Body.String(),
+				rec.Header().Get(HeaderCacheControl))
+		}
+	}
+}
+
+func TestRouterStatic_PrefixWithoutSlash(t *testing.T) {
+	s := newSlimTest()
+	s.Filesystem = fstest.MapFS{
+		"assets/index.html": {Data: []byte("home")},
+		"assets/a.txt":      {Data: []byte("a")},
+	}
+	s.Static("/static", "assets")
+
+	if rec := perform(t, s, http.MethodGet, "/static/a.txt", nil, nil); rec.Body.String() != "a" {
+		t.Fatalf("file: code=%d body=%q", rec.Code, rec.Body.String())
+	}
+	if rec := perform(t, s, http.MethodGet, "/static/", nil, nil); rec.Body.String() != "home" {
+		t.Fatalf("index: code=%d body=%q", rec.Code, rec.Body.String())
+	}
+	// without the HTML5 mode a missing file is not found
+	if rec := perform(t, s, http.MethodGet, "/static/missing", nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing: code=%d", rec.Code)
+	}
+}
+
+func TestRouterStatic_RouteMiddleware(t *testing.T) {
+	for _, tolerant := range []bool{false, true} {
+		s := newSlimTest()
+		r := NewRouter(RouterConfig{RoutingTrailingSlash: tolerant})
+		if x, ok := r.(*routerImpl); ok {
+			x.slim = s
+		}
+		s.router = r
+		s.Filesystem = fstest.MapFS{
+			"public/index.html": {Data: []byte("home")},
+			"public/a.txt":      {Data: []byte("a")},
+		}
+		route := s.Static("/app", "public").SetName("app")
+		route.Use(func(c Context, next HandlerFunc) error {
+			if c.QueryParam("token") == "" {
+				return c.NoContent(http.StatusForbidden)
+			}
+			return next(c)
+		})
+
+		// the middleware guards the index as well as the files
+		for _, target := range []string{"/app/", "/app/a.txt"} {
+			if rec := perform(t, s, http.MethodGet, target, nil, nil); rec.Code != http.StatusForbidden {
+				t.Fatalf("tolerant=%v %s: expected 403, got %d", tolerant, target, rec.Code)
+			}
+		}
+		if rec := perform(t, s, http.MethodGet, "/app/?token=1", nil, nil); rec.Body.String() != "home" {
+			t.Fatalf("tolerant=%v index: code=%d body=%q", tolerant, rec.Code, rec.Body.String())
+		}
+		if u := s.Router().Reverse("app", "a.txt"); u != "/app/a.txt" {
+			t.Fatalf("tolerant=%v reverse: %q", tolerant, u)
+		}
+		// the prefix without trailing slash follows RoutingTrailingSlash
+		rec := perform(t, s, http.MethodGet, "/app?token=1", nil, nil)
+		if tolerant && (rec.Code != http.StatusMovedPermanently || rec.Header().Get(HeaderLocation) != "/app/") ||
+			!tolerant && rec.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("tolerant=%v /app: code=%d headers=%v", tolerant, rec.Code, rec.Header())
+		}
+	}
+}
+
+func TestStatic_ConfigOptions(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "index.html", "index")
+	writeFile(t, dir, "css/site.css", "css")
+	writeFile(t, dir, ".htpasswd", "admin:x")
+
+	s := newSlimTest()
+	s.Use(StaticConfig{
+		Root:         dir,
+		HTML5:        true,
+		HTML5Exclude: []string{"/api/"},
+		CacheControl: []StaticCacheRule{{Pattern: "*.css", CacheControl: "max-age=60"}},
+		DenyHidden:   true,
+	}.ToMiddleware())
+	s.GET("/.well-known/health", func(c Context) error { return c.String(http.StatusOK, "ok") })
+
+	if rec := perform(t, s, http.MethodGet, "/css/site.css", nil, nil); rec.Body.String() != "css" ||
+		rec.Header().Get(HeaderCacheControl) != "max-age=60" {
+		t.Fatalf("css: code=%d body=%q headers=%v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	if rec := perform(t, s, http.MethodGet, "/route", nil, nil); rec.Body.String() != "index" {
+		t.Fatalf("fallback: code=%d body=%q", rec.Code, rec.Body.String())
+	}
+	if rec := perform(t, s, http.MethodGet, "/api/x", nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("excluded: code=%d", rec.Code)
+	}
+	// hidden files are left to the routes
+	if rec := perform(t, s, http.MethodGet, "/.well-known/health", nil, nil); rec.Body.String() != "ok" {
+		t.Fatalf("route: code=%d body=%q", rec.Code, rec.Body.String())
+	}
+	if rec := perform(t, s, http.MethodGet, "/.htpasswd", nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("hidden: code=%d body=%q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestStaticConfig_InvalidCachePattern(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	StaticConfig{CacheControl: []StaticCacheRule{{Pattern: "[", CacheControl: "no-store"}}}.ToMiddleware()
+}
+
+func TestStatic_FS(t *testing.T) {
+	s := newSlimTest()
+	s.Use(StaticConfig{FS: fstest.MapFS{"site/a.txt": {Data: []byte("a")}}, Root: "site"}.ToMiddleware())
+	if rec := perform(t, s, http.MethodGet, "/a.txt", nil, nil); rec.Body.String() != "a" {
+		t.Fatalf("code=%d body=%q", rec.Code, rec.Body.String())
+	}
+	if rec := perform(t, s, http.MethodGet, "/site/a.txt", nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,31 @@
+package slim
+
+// Translator translates messages for `Context.T`, see the i18n package for a
+// catalog based implementation.
+type Translator interface {
+	// Translate returns the message for key in locale, formatted with args.
+	// An empty locale selects the default locale of the translator.
+	Translate(locale, key string, args ...any) string
+}
+
+var localeKey = NewKey[string]("locale")
+
+// Locale returns the locale of the request set by `SetLocale`, or an empty
+// string when none was set.
+func (x *contextImpl) Locale() string {
+	return localeKey.Get(x)
+}
+
+// SetLocale sets the locale used by `T`.
+func (x *contextImpl) SetLocale(locale string) {
+	localeKey.Set(x, locale)
+}
+
+// T translates key in the request locale with `Slim.Translator`. It returns
+// the key itself when no translator is registered.
+func (x *contextImpl) T(key string, args ...any) string {
+	if x.slim.Translator == nil {
+		return key
+	}
+	return x.slim.Translator.Translate(x.Locale(), key, args...)
+}
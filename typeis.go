@@ -77,7 +77,7 @@ func TypeIs(value string, types ...string) (string, error) {
 			if cty.is("application", "x-www-form-urlencoded") {
 				return typ, nil
 			}
-		case "protobuf", "msgpack":
+		case "protobuf", "msgpack", "cbor":
 			if cty.is("application", typ) {
 				return typ, nil
 			}
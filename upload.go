@@ -0,0 +1,370 @@
+package slim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// UploadConfig defines the limits applied while streaming a multipart request.
+type UploadConfig struct {
+	// MaxFileSize is the largest size of a single file part.
+	// Optional. `Context.Upload` defaults it to `Slim.MultipartMemoryLimit`,
+	// otherwise it is unlimited.
+	MaxFileSize int64
+	// MaxFieldSize is the largest size of a single non-file part, read into
+	// memory by `Upload.SaveAll`. A negative value removes the limit.
+	// Optional. Default value 10MB.
+	MaxFieldSize int64
+	// MaxTotalSize is the largest size of all parts together. A negative
+	// value removes the limit.
+	// Optional. Default value 128MB.
+	MaxTotalSize int64
+	// MaxFiles is the largest number of file parts.
+	// Optional. Default value is unlimited.
+	MaxFiles int
+	// AllowedTypes lists the media types file parts may have. The type is
+	// sniffed from the content, the Content-Type sent by the client is not
+	// trusted. Entries may use a wildcard subtype, such as `image/*`.
+	// Optional. Default value allows any type.
+	AllowedTypes []string
+}
+
+// DefaultUploadConfig holds the limits used when UploadConfig leaves them unset.
+var DefaultUploadConfig = UploadConfig{
+	MaxFieldSize: 10 << 20,  // 10 MB, as http.Request.ParseMultipartForm
+	MaxTotalSize: 128 << 20, // 128 MB
+}
+
+// Upload iterates over the parts of a multipart request without spooling
+// them to memory or temporary files first.
+//
+//	u, err := c.Upload(slim.UploadConfig{MaxFileSize: 10 << 20, AllowedTypes: []string{"image/*"}})
+//	if err != nil {
+//		return err
+//	}
+//	files, values, err := u.SaveAll(slim.NewDirUploadStore("uploads"))
+type Upload struct {
+	reader *multipart.Reader
+	config UploadConfig
+	total  int64
+	files  int
+	part   *UploadPart
+}
+
+// NewUpload returns an Upload for the request, or `ErrUnsupportedMediaType`
+// if the request is not a multipart request.
+func NewUpload(r *http.Request, config UploadConfig) (*Upload, error) {
+	mt, params, err := mime.ParseMediaType(r.Header.Get(HeaderContentType))
+	if err != nil || !strings.HasPrefix(mt, "multipart/") {
+		return nil, ErrUnsupportedMediaType
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, NewHTTPError(http.StatusBadRequest, "missing multipart boundary")
+	}
+	if config.MaxFieldSize == 0 {
+		config.MaxFieldSize = DefaultUploadConfig.MaxFieldSize
+	}
+	if config.MaxTotalSize == 0 {
+		config.MaxTotalSize = DefaultUploadConfig.MaxTotalSize
+	}
+	return &Upload{
+		reader: multipart.NewReader(r.Body, boundary),
+		config: config,
+	}, nil
+}
+
+// NextPart returns the next part of the request, or `io.EOF` when there are
+// no more parts. The previous part is drained and becomes invalid.
+func (u *Upload) NextPart() (*UploadPart, error) {
+	if u.part != nil {
+		if _, err := io.Copy(io.Discard, u.part); err != nil {
+			return nil, err
+		}
+		u.part = nil
+	}
+	p, err := u.reader.NextPart()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, NewHTTPErrorWithInternal(http.StatusBadRequest, err, err.Error())
+	}
+	part := &UploadPart{
+		FieldName: p.FormName(),
+		Header:    p.Header,
+		part:      p,
+		upload:    u,
+		limit:     u.config.MaxFieldSize,
+	}
+	if name := p.FileName(); name != "" {
+		u.files++
+		if u.config.MaxFiles > 0 && u.files > u.config.MaxFiles {
+			return nil, ErrStatusRequestEntityTooLarge.WithInternal(
+				fmt.Errorf("slim: too many files, limit is %d", u.config.MaxFiles))
+		}
+		part.FileName = SanitizeFilename(name)
+		part.limit = u.config.MaxFileSize
+		if err = part.sniff(); err != nil {
+			return nil, err
+		}
+	}
+	u.part = part
+	return part, nil
+}
+
+// SaveAll saves every file part into store and collects the other parts as
+// form values.
+func (u *Upload) SaveAll(store UploadStore) ([]*UploadedFile, map[string][]string, error) {
+	var files []*UploadedFile
+	values := make(map[string][]string)
+	for {
+		part, err := u.NextPart()
+		if err == io.EOF {
+			return files, values, nil
+		}
+		if err != nil {
+			return files, values, err
+		}
+		if !part.IsFile() {
+			// the part fails once it exceeds MaxFieldSize, bounding the value
+			b, err := io.ReadAll(part)
+			if err != nil {
+				return files, values, err
+			}
+			values[part.FieldName] = append(values[part.FieldName], string(b))
+			continue
+		}
+		file, err := store.Save(part)
+		if err != nil {
+			return files, values, err
+		}
+		files = append(files, file)
+	}
+}
+
+// UploadPart is a single part of a multipart request. Reading from it
+// enforces the configured size limits.
+type UploadPart struct {
+	// FieldName is the name of the form field.
+	FieldName string
+	// FileName is the sanitized file name, empty for non-file parts.
+	FileName string
+	// ContentType is the media type sniffed from the content of file parts.
+	ContentType string
+	// Header is the MIME header of the part as sent by the client.
+	Header textproto.MIMEHeader
+
+	part   *multipart.Part
+	upload *Upload
+	head   []byte
+	limit  int64
+	size   int64
+}
+
+// IsFile reports whether the part is a file upload.
+func (p *UploadPart) IsFile() bool {
+	return p.FileName != ""
+}
+
+// Size returns the number of bytes read from the part so far.
+func (p *UploadPart) Size() int64 {
+	return p.size
+}
+
+// Read implements `io.Reader`. It fails with `ErrStatusRequestEntityTooLarge`
+// once a size limit is exceeded.
+func (p *UploadPart) Read(b []byte) (n int, err error) {
+	if len(p.head) > 0 {
+		n = copy(b, p.head)
+		p.head = p.head[n:]
+	} else {
+		n, err = p.part.Read(b)
+	}
+	if n > 0 {
+		if lerr := p.consume(int64(n)); lerr != nil {
+			return n, lerr
+		}
+	}
+	return n, err
+}
+
+func (p *UploadPart) consume(n int64) error {
+	p.size += n
+	p.upload.total += n
+	if p.limit > 0 && p.size > p.limit {
+		if !p.IsFile() {
+			return ErrStatusRequestEntityTooLarge.WithInternal(
+				fmt.Errorf("slim: field %q exceeds %d bytes", p.FieldName, p.limit))
+		}
+		return ErrStatusRequestEntityTooLarge.WithInternal(
+			fmt.Errorf("slim: file %q exceeds %d bytes", p.FileName, p.limit))
+	}
+	if limit := p.upload.config.MaxTotalSize; limit > 0 && p.upload.total > limit {
+		return ErrStatusRequestEntityTooLarge.WithInternal(
+			fmt.Errorf("slim: request exceeds %d bytes", limit))
+	}
+	return nil
+}
+
+// sniff peeks at the first 512 bytes to detect the media type of the part.
+func (p *UploadPart) sniff() error {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(p.part, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return NewHTTPErrorWithInternal(http.StatusBadRequest, err, err.Error())
+	}
+	p.head = head[:n]
+	p.ContentType = http.DetectContentType(p.head)
+	if len(p.upload.config.AllowedTypes) > 0 && !matchMediaType(p.ContentType, p.upload.config.AllowedTypes) {
+		return ErrUnsupportedMediaType.WithInternal(
+			fmt.Errorf("slim: file %q has disallowed type %s", p.FileName, p.ContentType))
+	}
+	return nil
+}
+
+func matchMediaType(ctype string, allowed []string) bool {
+	mt, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == "*/*" || a == mt {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(mt, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// SanitizeFilename strips directories, control characters and other
+// characters that are unsafe in file names from a client supplied name.
+func SanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = filepath.Base("/" + name)
+	name = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
+
+// UploadedFile describes a file part that has been saved by an UploadStore.
+type UploadedFile struct {
+	FieldName   string
+	FileName    string
+	ContentType string
+	Size        int64
+	// Location is where the store saved the file, such as a file path.
+	Location string
+}
+
+// UploadStore saves file parts.
+type UploadStore interface {
+	Save(p *UploadPart) (*UploadedFile, error)
+}
+
+// UploadStoreFunc is an adapter to use ordinary functions as UploadStore.
+type UploadStoreFunc func(p *UploadPart) (*UploadedFile, error)
+
+// Save implements UploadStore.
+func (f UploadStoreFunc) Save(p *UploadPart) (*UploadedFile, error) {
+	return f(p)
+}
+
+// NewWriterUploadStore returns an UploadStore that copies every file part to
+// the writer returned by open. The writer is closed afterward if it
+// implements `io.Closer`.
+func NewWriterUploadStore(open func(p *UploadPart) (io.Writer, error)) UploadStore {
+	return UploadStoreFunc(func(p *UploadPart) (*UploadedFile, error) {
+		w, err := open(p)
+		if err != nil {
+			return nil, err
+		}
+		n, err := io.Copy(w, p)
+		if c, ok := w.(io.Closer); ok {
+			if cerr := c.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &UploadedFile{
+			FieldName:   p.FieldName,
+			FileName:    p.FileName,
+			ContentType: p.ContentType,
+			Size:        n,
+		}, nil
+	})
+}
+
+// NewDirUploadStore returns an UploadStore that saves file parts into dir
+// using their sanitized names. Existing files are never overwritten, a
+// numeric suffix is appended instead. Partially written files are removed
+// when saving fails.
+func NewDirUploadStore(dir string) UploadStore {
+	return UploadStoreFunc(func(p *UploadPart) (*UploadedFile, error) {
+		f, err := createUniqueFile(dir, p.FileName)
+		if err != nil {
+			return nil, err
+		}
+		n, err := io.Copy(f, p)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return nil, err
+		}
+		return &UploadedFile{
+			FieldName:   p.FieldName,
+			FileName:    p.FileName,
+			ContentType: p.ContentType,
+			Size:        n,
+			Location:    f.Name(),
+		}, nil
+	})
+}
+
+func createUniqueFile(dir, name string) (*os.File, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 0; i < 1000; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, candidate), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("slim: cannot find a free file name for %q", name)
+}
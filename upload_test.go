@@ -0,0 +1,129 @@
+package slim
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A" + strings.Repeat("\x00", 32))
+
+func newUploadRequest(t *testing.T, fields map[string]string, files map[string][]byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(content)
+	}
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set(HeaderContentType, mw.FormDataContentType())
+	return r
+}
+
+func TestUpload_SaveAllToDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "avatar.png", "existing")
+	r := newUploadRequest(t, map[string]string{"name": "bob"}, map[string][]byte{`..\..\avatar.png`: pngHeader})
+
+	u, err := NewUpload(r, UploadConfig{AllowedTypes: []string{"image/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, values, err := u.SaveAll(NewDirUploadStore(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["name"][0] != "bob" || len(files) != 1 {
+		t.Fatalf("values=%v files=%v", values, files)
+	}
+	f := files[0]
+	if f.FileName != "avatar.png" || f.ContentType != "image/png" || f.Size != int64(len(pngHeader)) {
+		t.Fatalf("unexpected file %+v", f)
+	}
+	if f.Location != filepath.Join(dir, "avatar-1.png") {
+		t.Fatalf("existing files must not be overwritten, location=%s", f.Location)
+	}
+	if b, _ := os.ReadFile(f.Location); !bytes.Equal(b, pngHeader) {
+		t.Fatalf("content mismatch")
+	}
+}
+
+func TestUpload_Limits(t *testing.T) {
+	r := newUploadRequest(t, nil, map[string][]byte{"a.txt": bytes.Repeat([]byte("a"), 2048)})
+	u, _ := NewUpload(r, UploadConfig{MaxFileSize: 1024})
+	var sink bytes.Buffer
+	_, _, err := u.SaveAll(NewWriterUploadStore(func(p *UploadPart) (io.Writer, error) { return &sink, nil }))
+	var he *HTTPError
+	if !errors.As(err, &he) || he.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %v", err)
+	}
+
+	r = newUploadRequest(t, nil, map[string][]byte{"a.txt": []byte("plain text")})
+	u, _ = NewUpload(r, UploadConfig{AllowedTypes: []string{"image/png"}})
+	if _, err = u.NextPart(); !errors.As(err, &he) || he.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %v", err)
+	}
+
+	r = newUploadRequest(t, map[string]string{"k": strings.Repeat("v", 100)}, nil)
+	u, _ = NewUpload(r, UploadConfig{MaxTotalSize: 10})
+	if _, _, err = u.SaveAll(NewDirUploadStore(t.TempDir())); !errors.As(err, &he) || he.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %v", err)
+	}
+
+	if _, err = NewUpload(httptest.NewRequest(http.MethodPost, "/", nil), UploadConfig{}); err != ErrUnsupportedMediaType {
+		t.Fatalf("expected 415 for non multipart request, got %v", err)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	cases := map[string]string{
+		"report.pdf":          "report.pdf",
+		"../../etc/passwd":    "passwd",
+		`C:\Users\x\evil.exe`: "evil.exe",
+		".htaccess":           "htaccess",
+		"a\x00b<c>.txt":       "abc.txt",
+		"..":                  "file",
+		"":                    "file",
+	}
+	for in, want := range cases {
+		if got := SanitizeFilename(in); got != want {
+			t.Errorf("SanitizeFilename(%q)=%q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestUpload_FieldLimits(t *testing.T) {
+	r := newUploadRequest(t, map[string]string{"bio": strings.Repeat("x", 2048)}, nil)
+	u, _ := NewUpload(r, UploadConfig{MaxFieldSize: 1024})
+	_, _, err := u.SaveAll(NewDirUploadStore(t.TempDir()))
+	var he *HTTPError
+	if !errors.As(err, &he) || he.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %v", err)
+	}
+
+	r = newUploadRequest(t, map[string]string{"a": strings.Repeat("x", 600), "b": strings.Repeat("x", 600)}, nil)
+	u, _ = NewUpload(r, UploadConfig{MaxTotalSize: 1000})
+	if _, _, err = u.SaveAll(NewDirUploadStore(t.TempDir())); !errors.As(err, &he) || he.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %v", err)
+	}
+
+	u, _ = NewUpload(newUploadRequest(t, nil, nil), UploadConfig{})
+	if u.config.MaxFieldSize != DefaultUploadConfig.MaxFieldSize || u.config.MaxTotalSize != DefaultUploadConfig.MaxTotalSize {
+		t.Fatalf("defaults not applied: %+v", u.config)
+	}
+}
@@ -0,0 +1,674 @@
+package slim
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RuleFunc reports whether the field described by fl satisfies a rule.
+type RuleFunc func(fl FieldLevel) bool
+
+// FieldLevel describes the field a rule is evaluated against.
+type FieldLevel struct {
+	// Field is the value being validated. Pointers are dereferenced for every
+	// rule except `required`, nil pointers skip the other rules.
+	Field reflect.Value
+	// Parent is the struct that contains the field, used by cross-field rules.
+	Parent reflect.Value
+	// Param is the rule parameter, e.g. "64" for `max=64`.
+	Param string
+	// Path is the path of the field, e.g. "items[0].name".
+	Path string
+}
+
+// FieldError describes a single failed rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+	Value   any    `json:"-"`
+}
+
+// Error implements the error interface.
+func (fe *FieldError) Error() string {
+	return fe.Field + " " + fe.Message
+}
+
+// ValidationErrors is returned by DefaultValidator when one or more fields
+// are invalid. `Context.Validate` converts it into a 422 HTTPError whose
+// internal error is the ValidationErrors, so `errors.As` keeps working.
+type ValidationErrors []*FieldError
+
+// Error implements the error interface.
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, fe := range ve {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// HTTPError converts the validation errors into a 422 Unprocessable Entity
+// error carrying the field errors as its message.
+func (ve ValidationErrors) HTTPError() *HTTPError {
+	return NewHTTPErrorWithInternal(http.StatusUnprocessableEntity, ve, ve)
+}
+
+// DefaultValidator validates structs using the `validate` struct tag:
+//
+//	type SignUp struct {
+//		Name     string   `json:"name" validate:"required,min=1,max=64"`
+//		Email    string   `json:"email" validate:"required,email"`
+//		Role     string   `json:"role" validate:"oneof=admin member"`
+//		Password string   `json:"password" validate:"required,min=8"`
+//		Confirm  string   `json:"confirm" validate:"eqfield=Password"`
+//		Tags     []string `json:"tags" validate:"max=5,dive,min=1"`
+//		Code     string   `json:"code" validate:"omitempty,regex=^[A-Z]{3}$"`
+//	}
+//
+// Rules are separated by commas and evaluated in order, the first failing
+// rule of a field is reported. `regex` consumes the rest of the tag so the
+// expression may contain commas. `omitempty` skips the remaining rules for
+// zero values and nil pointers, a pointer to a zero value is not empty.
+// `dive` applies the remaining rules to every element of a slice, array or
+// map. Nested structs, including those inside slices and maps, are validated
+// recursively, self-referencing values only once. Field paths use the `json`
+// tag name when present.
+//
+// Built-in rules: required, omitempty, dive, len, min, max, gt, gte, lt, lte,
+// eq, ne, oneof, email, url, alpha, alphanum, numeric, regex, eqfield,
+// nefield, gtfield, gtefield, ltfield, ltefield.
+//
+// The tags of a struct type are parsed once and cached. An unknown rule, a
+// non-numeric size parameter or an invalid regular expression makes Validate
+// return an error describing the tag instead of ValidationErrors.
+type DefaultValidator struct {
+	rules  sync.Map // map[string]RuleFunc
+	checks sync.Map // map[string]func(param string) error
+	fields sync.Map // map[reflect.Type]*validatorPlan
+}
+
+// NewValidator returns a DefaultValidator with the built-in rules registered.
+func NewValidator() *DefaultValidator {
+	v := &DefaultValidator{}
+	for name, fn := range builtinRules {
+		v.rules.Store(name, fn)
+	}
+	for name, check := range builtinChecks {
+		v.checks.Store(name, check)
+	}
+	return v
+}
+
+// RegisterRule registers a custom rule, replacing any rule with the same name.
+func (v *DefaultValidator) RegisterRule(name string, fn RuleFunc) {
+	if name == "" || fn == nil {
+		panic("slim: validation rule requires a name and a function")
+	}
+	v.rules.Store(name, fn)
+	v.checks.Delete(name)
+	// the cached tags may refer to the previous rule
+	v.fields.Clear()
+}
+
+// Validate implements the Validator interface.
+func (v *DefaultValidator) Validate(i any) error {
+	var st validation
+	v.validateValue(reflect.ValueOf(i), "", &st)
+	if st.err != nil {
+		return st.err
+	}
+	if len(st.errs) > 0 {
+		return st.errs
+	}
+	return nil
+}
+
+// validation is the state of a single Validate call.
+type validation struct {
+	errs ValidationErrors
+	// err reports an invalid validate tag, which stops the validation
+	err error
+	// visiting holds the values being validated, to stop on self-references
+	visiting map[visit]bool
+}
+
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// enter marks val as being validated, it reports false when it already is.
+func (st *validation) enter(val reflect.Value) (visit, bool) {
+	key := visit{ptr: val.Pointer(), typ: val.Type()}
+	if val.Kind() == reflect.Slice {
+		key.len = val.Len()
+	}
+	if st.visiting[key] {
+		return key, false
+	}
+	if st.visiting == nil {
+		st.visiting = make(map[visit]bool)
+	}
+	st.visiting[key] = true
+	return key, true
+}
+
+type validatorRule struct {
+	name  string
+	param string
+	fn    RuleFunc
+}
+
+type validatorPlan struct {
+	fields []validatorField
+	err    error
+}
+
+type validatorField struct {
+	index    int
+	name     string
+	embedded bool
+	rules    []validatorRule
+	dive     []validatorRule
+	hasDive  bool
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (v *DefaultValidator) validateValue(val reflect.Value, path string, st *validation) {
+	if st.err != nil {
+		return
+	}
+	for val.Kind() == reflect.Pointer || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return
+		}
+		if val.Kind() == reflect.Pointer {
+			key, ok := st.enter(val)
+			if !ok {
+				return
+			}
+			defer delete(st.visiting, key)
+		}
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Struct:
+		if val.Type() != timeType {
+			v.validateStruct(val, path, st)
+		}
+	case reflect.Slice, reflect.Array:
+		if !mayContainStruct(val.Type().Elem()) {
+			return
+		}
+		if val.Kind() == reflect.Slice && !val.IsNil() {
+			key, ok := st.enter(val)
+			if !ok {
+				return
+			}
+			defer delete(st.visiting, key)
+		}
+		for i := 0; i < val.Len(); i++ {
+			v.validateValue(val.Index(i), path+"["+strconv.Itoa(i)+"]", st)
+		}
+	case reflect.Map:
+		if !mayContainStruct(val.Type().Elem()) || val.IsNil() {
+			return
+		}
+		key, ok := st.enter(val)
+		if !ok {
+			return
+		}
+		defer delete(st.visiting, key)
+		iter := val.MapRange()
+		for iter.Next() {
+			v.validateValue(iter.Value(), path+"["+fmt.Sprint(iter.Key().Interface())+"]", st)
+		}
+	}
+}
+
+func mayContainStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return t != timeType
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+func (v *DefaultValidator) validateStruct(val reflect.Value, path string, st *validation) {
+	plan := v.structFields(val.Type())
+	if plan.err != nil {
+		st.err = plan.err
+		return
+	}
+	for _, f := range plan.fields {
+		if st.err != nil {
+			return
+		}
+		fv := val.Field(f.index)
+		if f.embedded {
+			v.validateValue(fv, path, st)
+			continue
+		}
+		p := f.name
+		if path != "" {
+			p = path + "." + f.name
+		}
+		if !applyRules(f.rules, fv, val, p, &st.errs) {
+			continue
+		}
+		if !f.hasDive {
+			v.validateValue(fv, p, st)
+			continue
+		}
+		elems := fv
+		for elems.Kind() == reflect.Pointer && !elems.IsNil() {
+			elems = elems.Elem()
+		}
+		switch elems.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < elems.Len(); i++ {
+				ep := p + "[" + strconv.Itoa(i) + "]"
+				if applyRules(f.dive, elems.Index(i), val, ep, &st.errs) {
+					v.validateValue(elems.Index(i), ep, st)
+				}
+			}
+		case reflect.Map:
+			iter := elems.MapRange()
+			for iter.Next() {
+				ep := p + "[" + fmt.Sprint(iter.Key().Interface()) + "]"
+				if applyRules(f.dive, iter.Value(), val, ep, &st.errs) {
+					v.validateValue(iter.Value(), ep, st)
+				}
+			}
+		}
+	}
+}
+
+// applyRules evaluates the rules in order and records the first failure.
+func applyRules(rules []validatorRule, field, parent reflect.Value, path string, errs *ValidationErrors) bool {
+	if len(rules) == 0 {
+		return true
+	}
+	target := field
+	for target.Kind() == reflect.Pointer || target.Kind() == reflect.Interface {
+		if target.IsNil() {
+			break
+		}
+		target = target.Elem()
+	}
+	isNil := (target.Kind() == reflect.Pointer || target.Kind() == reflect.Interface) && target.IsNil()
+	for _, r := range rules {
+		switch r.name {
+		case "omitempty":
+			// a pointer is empty when nil, not when it points to a zero value
+			if isNil || !target.IsValid() || field.Kind() != reflect.Pointer && target.IsZero() {
+				return true
+			}
+			continue
+		case "required":
+			if isNil || !target.IsValid() || (field.Kind() != reflect.Pointer && isEmpty(target)) {
+				*errs = append(*errs, newFieldError(r, target, path))
+				return false
+			}
+			continue
+		}
+		if isNil {
+			return true
+		}
+		fl := FieldLevel{Field: target, Parent: parent, Param: r.param, Path: path}
+		if !r.fn(fl) {
+			*errs = append(*errs, newFieldError(r, target, path))
+			return false
+		}
+	}
+	return true
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String, reflect.Chan:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func (v *DefaultValidator) structFields(t reflect.Type) *validatorPlan {
+	if cached, ok := v.fields.Load(t); ok {
+		return cached.(*validatorPlan)
+	}
+	plan := &validatorPlan{}
+	defer v.fields.Store(t, plan)
+	var fields []validatorField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("validate")
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && !hasTag {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && sf.IsExported() {
+				fields = append(fields, validatorField{index: i, embedded: true})
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		f := validatorField{index: i, name: sf.Name}
+		if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+			f.name = name
+		}
+		rules := parseRules(tag)
+		for j := range rules {
+			if err := v.compileRule(&rules[j]); err != nil {
+				plan.err = fmt.Errorf("slim: invalid validate tag on %s.%s: %w", t, sf.Name, err)
+				return plan
+			}
+		}
+		for j, r := range rules {
+			if r.name == "dive" {
+				f.hasDive = true
+				f.dive = rules[j+1:]
+				rules = rules[:j]
+				break
+			}
+		}
+		f.rules = rules
+		fields = append(fields, f)
+	}
+	plan.fields = fields
+	return plan
+}
+
+// compileRule resolves the function of a rule and checks its parameter.
+func (v *DefaultValidator) compileRule(r *validatorRule) error {
+	switch r.name {
+	case "omitempty", "required", "dive":
+		return nil
+	}
+	fn, ok := v.rules.Load(r.name)
+	if !ok {
+		return fmt.Errorf("unknown rule %q", r.name)
+	}
+	r.fn = fn.(RuleFunc)
+	if check, ok := v.checks.Load(r.name); ok {
+		return check.(func(string) error)(r.param)
+	}
+	return nil
+}
+
+func parseRules(tag string) []validatorRule {
+	var rules []validatorRule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regex=") {
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			rules = append(rules, validatorRule{name: name, param: param})
+		}
+	}
+	return rules
+}
+
+func newFieldError(r validatorRule, v reflect.Value, path string) *FieldError {
+	fe := &FieldError{Field: path, Rule: r.name, Param: r.param}
+	if v.IsValid() && v.CanInterface() {
+		fe.Value = v.Interface()
+	}
+	fe.Message = ruleMessage(r, v)
+	return fe
+}
+
+func ruleMessage(r validatorRule, v reflect.Value) string {
+	unit := ""
+	if v.IsValid() {
+		switch v.Kind() {
+		case reflect.String:
+			unit = " characters"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			unit = " items"
+		}
+	}
+	switch r.name {
+	case "required":
+		return "is required"
+	case "len":
+		return "must be exactly " + r.param + unit
+	case "min", "gte":
+		return "must be at least " + r.param + unit
+	case "max", "lte":
+		return "must be at most " + r.param + unit
+	case "gt":
+		return "must be greater than " + r.param + unit
+	case "lt":
+		return "must be less than " + r.param + unit
+	case "eq":
+		return "must be equal to " + r.param
+	case "ne":
+		return "must not be equal to " + r.param
+	case "oneof":
+		return "must be one of [" + r.param + "]"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "alpha":
+		return "must contain only letters"
+	case "alphanum":
+		return "must contain only letters and numbers"
+	case "numeric":
+		return "must be numeric"
+	case "regex":
+		return "must match " + r.param
+	case "eqfield":
+		return "must be equal to " + r.param
+	case "nefield":
+		return "must not be equal to " + r.param
+	case "gtfield":
+		return "must be greater than " + r.param
+	case "gtefield":
+		return "must be greater than or equal to " + r.param
+	case "ltfield":
+		return "must be less than " + r.param
+	case "ltefield":
+		return "must be less than or equal to " + r.param
+	}
+	return "failed on the '" + r.name + "' rule"
+}
+
+var builtinRules = map[string]RuleFunc{
+	"len": sizeRule(func(n, p float64) bool { return n == p }),
+	"min": sizeRule(func(n, p float64) bool { return n >= p }),
+	"gte": sizeRule(func(n, p float64) bool { return n >= p }),
+	"max": sizeRule(func(n, p float64) bool { return n <= p }),
+	"lte": sizeRule(func(n, p float64) bool { return n <= p }),
+	"gt":  sizeRule(func(n, p float64) bool { return n > p }),
+	"lt":  sizeRule(func(n, p float64) bool { return n < p }),
+	"eq": func(fl FieldLevel) bool {
+		return fmt.Sprint(fl.Field.Interface()) == fl.Param
+	},
+	"ne": func(fl FieldLevel) bool {
+		return fmt.Sprint(fl.Field.Interface()) != fl.Param
+	},
+	"oneof": func(fl FieldLevel) bool {
+		s := fmt.Sprint(fl.Field.Interface())
+		for _, o := range strings.Fields(fl.Param) {
+			if s == o {
+				return true
+			}
+		}
+		return false
+	},
+	"email": stringRule(func(s string) bool {
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	}),
+	"url": stringRule(func(s string) bool {
+		u, err := url.ParseRequestURI(s)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	}),
+	"alpha": stringRule(func(s string) bool {
+		return s != "" && strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) }) == -1
+	}),
+	"alphanum": stringRule(func(s string) bool {
+		return s != "" && strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) == -1
+	}),
+	"numeric": stringRule(func(s string) bool {
+		_, err := strconv.ParseFloat(s, 64)
+		return err == nil
+	}),
+	"regex": func(fl FieldLevel) bool {
+		if fl.Field.Kind() != reflect.String {
+			return false
+		}
+		re, err := compileRegex(fl.Param)
+		return err == nil && re.MatchString(fl.Field.String())
+	},
+	"eqfield":  fieldRule(func(c int) bool { return c == 0 }),
+	"nefield":  fieldRule(func(c int) bool { return c != 0 }),
+	"gtfield":  fieldRule(func(c int) bool { return c > 0 }),
+	"gtefield": fieldRule(func(c int) bool { return c >= 0 }),
+	"ltfield":  fieldRule(func(c int) bool { return c < 0 }),
+	"ltefield": fieldRule(func(c int) bool { return c <= 0 }),
+}
+
+// builtinChecks validate the parameters of the built-in rules when the tags
+// are parsed, so that a typo fails every request the same way.
+var builtinChecks = map[string]func(param string) error{
+	"len":   checkNumber,
+	"min":   checkNumber,
+	"gte":   checkNumber,
+	"max":   checkNumber,
+	"lte":   checkNumber,
+	"gt":    checkNumber,
+	"lt":    checkNumber,
+	"regex": func(param string) error { _, err := compileRegex(param); return err },
+}
+
+func checkNumber(param string) error {
+	if _, err := strconv.ParseFloat(param, 64); err != nil {
+		return fmt.Errorf("parameter %q is not a number", param)
+	}
+	return nil
+}
+
+var regexCache sync.Map // map[string]*regexp.Regexp
+
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(expr, re)
+	return re, nil
+}
+
+func stringRule(fn func(s string) bool) RuleFunc {
+	return func(fl FieldLevel) bool {
+		return fl.Field.Kind() == reflect.String && fn(fl.Field.String())
+	}
+}
+
+// sizeRule compares the size of a field with the rule parameter: numbers are
+// compared by value, strings by rune count and collections by length.
+func sizeRule(cmp func(n, p float64) bool) RuleFunc {
+	return func(fl FieldLevel) bool {
+		p, err := strconv.ParseFloat(fl.Param, 64)
+		if err != nil {
+			return false
+		}
+		n, ok := size(fl.Field)
+		return ok && cmp(n, p)
+	}
+}
+
+func size(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// fieldRule compares a field with the sibling field named by the parameter.
+func fieldRule(ok func(c int) bool) RuleFunc {
+	return func(fl FieldLevel) bool {
+		if fl.Parent.Kind() != reflect.Struct {
+			return false
+		}
+		other := fl.Parent.FieldByName(fl.Param)
+		for other.Kind() == reflect.Pointer && !other.IsNil() {
+			other = other.Elem()
+		}
+		c, comparable := compareValues(fl.Field, other)
+		return comparable && ok(c)
+	}
+}
+
+func compareValues(a, b reflect.Value) (int, bool) {
+	if !a.IsValid() || !b.IsValid() {
+		return 0, false
+	}
+	if a.Type() == timeType && b.Type() == timeType {
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time)), true
+	}
+	if a.Kind() == reflect.String && b.Kind() == reflect.String {
+		return strings.Compare(a.String(), b.String()), true
+	}
+	if x, ok := size(a); ok && a.Kind() != reflect.Slice && a.Kind() != reflect.Map && a.Kind() != reflect.Array {
+		if y, ok := size(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if a.Type() == b.Type() && a.CanInterface() && b.CanInterface() {
+		if reflect.DeepEqual(a.Interface(), b.Interface()) {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
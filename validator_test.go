@@ -0,0 +1,199 @@
+package slim
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type vAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"len=5,numeric"`
+}
+
+type vSignUp struct {
+	Name      string            `json:"name" validate:"required,min=2,max=8"`
+	Email     string            `json:"email" validate:"required,email"`
+	Role      string            `json:"role" validate:"oneof=admin member"`
+	Password  string            `json:"password" validate:"required,min=8"`
+	Confirm   string            `json:"confirm" validate:"eqfield=Password"`
+	Age       *int              `json:"age" validate:"omitempty,gte=18"`
+	Code      string            `json:"code" validate:"omitempty,regex=^[A-Z]{2,3}$"`
+	Tags      []string          `json:"tags" validate:"max=3,dive,min=2"`
+	Address   vAddress          `json:"address"`
+	Addresses []*vAddress       `json:"addresses"`
+	Labels    map[string]string `json:"labels" validate:"dive,alpha"`
+	StartAt   time.Time         `json:"start_at"`
+	EndAt     time.Time         `json:"end_at" validate:"gtfield=StartAt"`
+}
+
+func validSignUp() vSignUp {
+	now := time.Now()
+	return vSignUp{
+		Name:      "bob",
+		Email:     "bob@example.com",
+		Role:      "admin",
+		Password:  "secret123",
+		Confirm:   "secret123",
+		Code:      "ABC",
+		Tags:      []string{"go", "web"},
+		Address:   vAddress{City: "Paris", Zip: "75001"},
+		Addresses: []*vAddress{{City: "Lyon", Zip: "69001"}},
+		Labels:    map[string]string{"env": "prod"},
+		StartAt:   now,
+		EndAt:     now.Add(time.Hour),
+	}
+}
+
+func fieldsOf(err error) map[string]string {
+	var ve ValidationErrors
+	out := map[string]string{}
+	if errors.As(err, &ve) {
+		for _, fe := range ve {
+			out[fe.Field] = fe.Rule
+		}
+	}
+	return out
+}
+
+func TestDefaultValidator_Valid(t *testing.T) {
+	v := NewValidator()
+	s := validSignUp()
+	if err := v.Validate(&s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDefaultValidator_Errors(t *testing.T) {
+	v := NewValidator()
+	s := validSignUp()
+	age := 16
+	s.Name = "b"
+	s.Email = "not-an-email"
+	s.Role = "root"
+	s.Confirm = "other"
+	s.Age = &age
+	s.Code = "abc"
+	s.Tags = []string{"go", "x"}
+	s.Address.Zip = "123"
+	s.Addresses = append(s.Addresses, &vAddress{Zip: "12345"})
+	s.Labels["bad"] = "a1"
+	s.EndAt = s.StartAt.Add(-time.Hour)
+
+	got := fieldsOf(v.Validate(s))
+	want := map[string]string{
+		"name":              "min",
+		"email":             "email",
+		"role":              "oneof",
+		"confirm":           "eqfield",
+		"age":               "gte",
+		"code":              "regex",
+		"tags[1]":           "min",
+		"address.zip":       "len",
+		"addresses[1].city": "required",
+		"labels[bad]":       "alpha",
+		"end_at":            "gtfield",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, rule := range want {
+		if got[k] != rule {
+			t.Errorf("%s: got rule %q, want %q", k, got[k], rule)
+		}
+	}
+}
+
+func TestDefaultValidator_CustomRuleAndHTTPError(t *testing.T) {
+	v := NewValidator()
+	v.RegisterRule("even", func(fl FieldLevel) bool { return fl.Field.Int()%2 == 0 })
+	type S struct {
+		N int `validate:"even"`
+	}
+	err := v.Validate(S{N: 3})
+	var ve ValidationErrors
+	if !errors.As(err, &ve) || ve[0].Field != "N" || !strings.Contains(ve[0].Error(), "even") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := New()
+	s.Validator = v
+	c := s.NewContext(nil, nil)
+	err = c.Validate(S{N: 3})
+	var he *HTTPError
+	if !errors.As(err, &he) || he.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 HTTPError, got %v", err)
+	}
+	if !errors.As(err, &ve) {
+		t.Fatalf("ValidationErrors must be reachable through the HTTPError")
+	}
+}
+
+func TestDefaultValidator_InvalidTags(t *testing.T) {
+	type Unknown struct {
+		N int `validate:"required,evn"`
+	}
+	type BadParam struct {
+		S string `validate:"min=abc"`
+	}
+	type BadRegex struct {
+		S string `validate:"regex=[a-"`
+	}
+	type Nested struct {
+		Items []BadParam `json:"items"`
+	}
+	v := NewValidator()
+	for _, i := range []any{Unknown{N: 1}, BadParam{}, &BadRegex{}, Nested{Items: []BadParam{{}}}} {
+		err := v.Validate(i)
+		var ve ValidationErrors
+		if err == nil || errors.As(err, &ve) || !strings.Contains(err.Error(), "invalid validate tag") {
+			t.Errorf("%T: got %v", i, err)
+		}
+	}
+
+	// registering the rule afterwards fixes the tag
+	v.RegisterRule("evn", func(fl FieldLevel) bool { return fl.Field.Int()%2 == 0 })
+	if got := fieldsOf(v.Validate(Unknown{N: 1})); got["N"] != "evn" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestDefaultValidator_OmitEmptyPointer(t *testing.T) {
+	type S struct {
+		N *int `validate:"omitempty,gte=1"`
+	}
+	v := NewValidator()
+	if err := v.Validate(S{}); err != nil {
+		t.Fatalf("nil pointer must be omitted: %v", err)
+	}
+	zero := 0
+	if got := fieldsOf(v.Validate(S{N: &zero})); got["N"] != "gte" {
+		t.Fatalf("pointer to zero must be validated, got %v", got)
+	}
+}
+
+type vNode struct {
+	Name     string            `json:"name" validate:"required"`
+	Next     *vNode            `json:"next"`
+	Children map[string]*vNode `json:"children"`
+}
+
+func TestDefaultValidator_SelfReference(t *testing.T) {
+	a := &vNode{Name: "a"}
+	b := &vNode{Next: a}
+	a.Next = b
+	a.Children = map[string]*vNode{"self": a}
+	got := fieldsOf(NewValidator().Validate(a))
+	if len(got) != 1 || got["next.name"] != "required" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestContext_ValidateWithoutValidator(t *testing.T) {
+	c := New().NewContext(nil, nil)
+	if err := c.Validate(struct{}{}); err != ErrValidatorNotRegistered {
+		t.Fatalf("got %v, want ErrValidatorNotRegistered", err)
+	}
+}